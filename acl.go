@@ -0,0 +1,130 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/czembower/vault-auditor/utils"
+)
+
+// scopedRule is a policy rule together with the namespace of the policy that
+// defines it, which determines the full path the rule applies to.
+type scopedRule struct {
+	Namespace string
+	Rule      policyRule
+}
+
+// policyRules returns the rules of the given policy scoped to its namespace.
+func policyRules(namespace string, p policy) []scopedRule {
+	rules := make([]scopedRule, 0, len(p.Rules))
+	for _, rule := range p.Rules {
+		rules = append(rules, scopedRule{Namespace: namespace, Rule: rule})
+	}
+	return rules
+}
+
+// pattern returns the rule's path prefixed with the path of its namespace.
+func (r scopedRule) pattern() string {
+	return utils.SetNamespacePath(r.Namespace) + r.Rule.Path
+}
+
+// resolveCapabilities returns the capabilities granted on path by the given
+// set of rules, following Vault's ACL evaluation: only the highest priority
+// matching pattern applies, rules sharing that pattern are merged, and deny
+// takes precedence over any other capability. A nil result means that no
+// rule matched the path.
+func resolveCapabilities(rules []scopedRule, path string) []string {
+	var best string
+	var matched []scopedRule
+
+	for _, rule := range rules {
+		if !checkForPolicyMatch(rule.Namespace, rule.Rule.Path, path) {
+			continue
+		}
+		pattern := rule.pattern()
+		switch {
+		case matched == nil || hasHigherPriority(pattern, best):
+			best = pattern
+			matched = []scopedRule{rule}
+		case pattern == best:
+			matched = append(matched, rule)
+		}
+	}
+
+	if matched == nil {
+		return nil
+	}
+
+	capabilities := []string{}
+	for _, rule := range matched {
+		if utils.StringInSlice("deny", rule.Rule.Capabilities) {
+			return []string{"deny"}
+		}
+		for _, capability := range rule.Rule.Capabilities {
+			if !utils.StringInSlice(capability, capabilities) {
+				capabilities = append(capabilities, capability)
+			}
+		}
+	}
+	sort.Strings(capabilities)
+
+	return capabilities
+}
+
+// hasHigherPriority reports whether policy path a takes priority over policy
+// path b when both match a request, per Vault's priority matching rules:
+//  1. the path whose first wildcard (+) or glob (*) occurs later wins
+//  2. a path not ending in * wins over one that does
+//  3. the path with fewer + segments wins
+//  4. the longer path wins
+//  5. the lexicographically greater path wins
+func hasHigherPriority(a, b string) bool {
+	aWildcard, bWildcard := firstWildcard(a), firstWildcard(b)
+	if aWildcard != bWildcard {
+		return aWildcard > bWildcard
+	}
+
+	aGlob, bGlob := strings.HasSuffix(a, "*"), strings.HasSuffix(b, "*")
+	if aGlob != bGlob {
+		return bGlob
+	}
+
+	aSegments, bSegments := countSegmentWildcards(a), countSegmentWildcards(b)
+	if aSegments != bSegments {
+		return aSegments < bSegments
+	}
+
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+
+	return a > b
+}
+
+func firstWildcard(path string) int {
+	if idx := strings.IndexAny(path, "+*"); idx >= 0 {
+		return idx
+	}
+	return len(path)
+}
+
+func countSegmentWildcards(path string) int {
+	count := 0
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "+" {
+			count++
+		}
+	}
+	return count
+}
+
+// grantsCapability reports whether any of the wanted capabilities is present
+// in the given, already resolved, capability list.
+func grantsCapability(capabilities []string, wanted ...string) bool {
+	for _, capability := range wanted {
+		if utils.StringInSlice(capability, capabilities) {
+			return true
+		}
+	}
+	return false
+}
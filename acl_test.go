@@ -0,0 +1,147 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveCapabilities(t *testing.T) {
+	rule := func(path string, capabilities ...string) scopedRule {
+		return scopedRule{Namespace: "root", Rule: policyRule{Path: path, Capabilities: capabilities}}
+	}
+
+	tests := []struct {
+		name  string
+		rules []scopedRule
+		path  string
+		want  []string
+	}{
+		{
+			name:  "no match",
+			rules: []scopedRule{rule("secret/data/other", "read")},
+			path:  "secret/data/app",
+			want:  nil,
+		},
+		{
+			name:  "exact path over glob",
+			rules: []scopedRule{rule("secret/data/*", "read", "list"), rule("secret/data/app", "update")},
+			path:  "secret/data/app",
+			want:  []string{"update"},
+		},
+		{
+			name:  "longer glob over shorter glob",
+			rules: []scopedRule{rule("secret/*", "read"), rule("secret/data/*", "update")},
+			path:  "secret/data/app",
+			want:  []string{"update"},
+		},
+		{
+			name:  "later wildcard wins over earlier wildcard",
+			rules: []scopedRule{rule("secret/+/app", "read"), rule("secret/data/*", "update")},
+			path:  "secret/data/app",
+			want:  []string{"update"},
+		},
+		{
+			name:  "+ over * at the same position",
+			rules: []scopedRule{rule("secret/data/*", "read"), rule("secret/data/+", "update")},
+			path:  "secret/data/app",
+			want:  []string{"update"},
+		},
+		{
+			name:  "fewer + segments wins",
+			rules: []scopedRule{rule("secret/+/+", "read"), rule("secret/+/app", "update")},
+			path:  "secret/data/app",
+			want:  []string{"update"},
+		},
+		{
+			name:  "deny on a less specific glob does not override an exact path",
+			rules: []scopedRule{rule("secret/*", "deny"), rule("secret/data/app", "read")},
+			path:  "secret/data/app",
+			want:  []string{"read"},
+		},
+		{
+			name:  "deny on the most specific path",
+			rules: []scopedRule{rule("secret/data/*", "read"), rule("secret/data/app", "deny")},
+			path:  "secret/data/app",
+			want:  []string{"deny"},
+		},
+		{
+			name:  "equal priority rules merge",
+			rules: []scopedRule{rule("secret/data/*", "read"), rule("secret/data/*", "update", "read")},
+			path:  "secret/data/app",
+			want:  []string{"read", "update"},
+		},
+		{
+			name:  "deny overrides equal priority rules",
+			rules: []scopedRule{rule("secret/data/*", "read"), rule("secret/data/*", "deny")},
+			path:  "secret/data/app",
+			want:  []string{"deny"},
+		},
+		{
+			name: "namespaced policy",
+			rules: []scopedRule{
+				{Namespace: "team-a", Rule: policyRule{Path: "secret/data/*", Capabilities: []string{"read"}}},
+				rule("team-a/secret/data/app", "update"),
+			},
+			path: "team-a/secret/data/app",
+			want: []string{"update"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveCapabilities(tt.rules, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveCapabilities = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveCapabilitiesAcrossPolicies(t *testing.T) {
+	i := &vaultInventory{Namespaces: []namespaceInventory{{
+		Name: "root",
+		Policies: []policy{
+			{Name: "reader", Rules: []policyRule{{Path: "secret/data/*", Capabilities: []string{"read", "list"}}}},
+			{Name: "writer", Rules: []policyRule{{Path: "secret/data/*", Capabilities: []string{"create", "update"}}}},
+			{Name: "narrow", Rules: []policyRule{{Path: "secret/data/app", Capabilities: []string{"read"}}}},
+		},
+	}}}
+
+	tests := []struct {
+		policies []string
+		path     string
+		want     []string
+	}{
+		{[]string{"reader", "writer"}, "secret/data/other", []string{"create", "list", "read", "update"}},
+		{[]string{"reader", "writer", "narrow"}, "secret/data/app", []string{"read"}},
+		{[]string{"writer", "missing"}, "secret/data/app", []string{"create", "update"}},
+	}
+
+	for _, tt := range tests {
+		if got := resolveCapabilities(i.policySetRules("root", tt.policies), tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("capabilities of %v on %s = %v, want %v", tt.policies, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestHasHigherPriority(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"secret/data/app", "secret/data/app*", true},
+		{"secret/data/app*", "secret/data/*", true},
+		{"secret/data/+", "secret/+/app", true},
+		{"secret/+/app", "secret/+/+", true},
+		{"secret/data/ab", "secret/data/a", true},
+		{"secret/data/b", "secret/data/a", true},
+	}
+
+	for _, tt := range tests {
+		if got := hasHigherPriority(tt.a, tt.b); got != tt.want {
+			t.Errorf("hasHigherPriority(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := hasHigherPriority(tt.b, tt.a); got == tt.want {
+			t.Errorf("hasHigherPriority(%q, %q) = %v, want %v", tt.b, tt.a, got, !tt.want)
+		}
+	}
+}
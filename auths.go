@@ -12,6 +12,9 @@ import (
 type authRole struct {
 	Name     string   `json:"name,omitempty"`
 	Policies []string `json:"policies,omitempty"`
+	// Groups lists the auth method groups a user of the ldap or okta
+	// methods belongs to, whose policies it inherits at login
+	Groups []string `json:"groups,omitempty"`
 }
 
 func (ns *namespaceInventory) scanAuths(c *clientConfig) {
@@ -22,6 +25,8 @@ func (ns *namespaceInventory) scanAuths(c *clientConfig) {
 	authMethodsWithRole := strings.Split(authMethodsWithRole, ", ")
 	authMethodsWithRoles := strings.Split(authMethodsWithRoles, ", ")
 	authMethodsWithCerts := strings.Split(authMethodsWithCerts, ", ")
+	authMethodsWithUsers := strings.Split(authMethodsWithUsers, ", ")
+	authMethodsWithGroups := strings.Split(authMethodsWithGroups, ", ")
 
 	appendAuthData := func(amIdx int, item authRole, dataType string) {
 		mu.Lock()
@@ -30,6 +35,10 @@ func (ns *namespaceInventory) scanAuths(c *clientConfig) {
 			ns.AuthMounts[amIdx].Roles = append(ns.AuthMounts[amIdx].Roles, item)
 		case "certs":
 			ns.AuthMounts[amIdx].Certs = append(ns.AuthMounts[amIdx].Certs, item)
+		case "users":
+			ns.AuthMounts[amIdx].Users = append(ns.AuthMounts[amIdx].Users, item)
+		case "groups":
+			ns.AuthMounts[amIdx].Groups = append(ns.AuthMounts[amIdx].Groups, item)
 		}
 		mu.Unlock()
 	}
@@ -79,6 +88,12 @@ func (ns *namespaceInventory) scanAuths(c *clientConfig) {
 			if utils.StringInSlice(am.Type, authMethodsWithCerts) {
 				listAndProcess("certs", "certs")
 			}
+			if utils.StringInSlice(am.Type, authMethodsWithUsers) {
+				listAndProcess("users", "users")
+			}
+			if utils.StringInSlice(am.Type, authMethodsWithGroups) {
+				listAndProcess("groups", "groups")
+			}
 
 			mu.Lock()
 			ns.Errors = append(ns.Errors, localErrors...)
@@ -88,30 +103,77 @@ func (ns *namespaceInventory) scanAuths(c *clientConfig) {
 	wg.Wait()
 }
 
-func getAuthRole(c *clientConfig, namespace *namespaceInventory, mount string, role string, rolePath string) authRole {
-	var roleData authRole
-	var policiesInt interface{}
+// userPolicies returns the policies of a user of the auth mount, including
+// those inherited from the auth method groups it is a member of.
+func (am authMount) userPolicies(user authRole) []string {
+	policies := append([]string{}, user.Policies...)
+	for _, name := range user.Groups {
+		for _, group := range am.Groups {
+			if !strings.EqualFold(group.Name, name) {
+				continue
+			}
+			for _, policy := range group.Policies {
+				if !utils.StringInSlice(policy, policies) {
+					policies = append(policies, policy)
+				}
+			}
+		}
+	}
+	return policies
+}
 
+func getAuthRole(c *clientConfig, namespace *namespaceInventory, mount string, role string, rolePath string) authRole {
 	roleResp, err := c.Client.Read(c.Ctx, "auth/"+mount+rolePath+"/"+role, vault.WithNamespace(namespace.Name))
 	if err != nil {
 		namespace.Errors = append(namespace.Errors, fmt.Sprintf("error reading path %s: %v", "auth/"+mount+rolePath+"/"+role, err))
-	} else {
-		if v, ok := roleResp.Data["token_policies"]; ok {
-			policiesInt = v.([]interface{})
-		}
-		if v, ok := roleResp.Data["allowed_policies"]; ok {
-			policiesInt = v.([]interface{})
-		}
+		return authRole{Name: role, Policies: []string{}}
 	}
 
-	if policiesInt == nil {
-		roleData.Policies = []string{}
-	} else {
-		for _, policy := range policiesInt.([]interface{}) {
-			roleData.Policies = append(roleData.Policies, policy.(string))
+	return parseAuthRole(role, roleResp.Data)
+}
+
+// parseAuthRole reads the policies and group memberships from the
+// configuration of an auth method role, user, group or cert.
+func parseAuthRole(name string, data map[string]interface{}) authRole {
+	roleData := authRole{Name: name, Policies: []string{}}
+
+	var policies []string
+	for _, key := range []string{"token_policies", "allowed_policies"} {
+		if v, ok := data[key]; ok {
+			policies = configStrings(v)
 		}
 	}
-	roleData.Name = role
+	// users and groups of the ldap, okta, radius and kerberos methods
+	// only carry the legacy policies field
+	if len(policies) == 0 {
+		policies = configStrings(data["policies"])
+	}
+	roleData.Policies = append(roleData.Policies, policies...)
+
+	// ldap reports the groups of a user as a comma-separated string, okta
+	// as a list
+	roleData.Groups = configStrings(data["groups"])
 
 	return roleData
 }
+
+// configStrings returns a list of strings from a configuration value given
+// either as a list or as a comma-separated string.
+func configStrings(v interface{}) []string {
+	var values []string
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAuthRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     map[string]interface{}
+		policies []string
+		groups   []string
+	}{
+		{
+			name:     "token policies",
+			data:     map[string]interface{}{"token_policies": []interface{}{"app"}, "policies": []interface{}{"app"}},
+			policies: []string{"app"},
+		},
+		{
+			name:     "allowed policies",
+			data:     map[string]interface{}{"allowed_policies": []interface{}{"ops"}},
+			policies: []string{"ops"},
+		},
+		{
+			name:     "legacy policies fallback",
+			data:     map[string]interface{}{"policies": []interface{}{"legacy", "other"}},
+			policies: []string{"legacy", "other"},
+		},
+		{
+			name:     "legacy policies with empty token policies",
+			data:     map[string]interface{}{"token_policies": []interface{}{}, "policies": []interface{}{"legacy"}},
+			policies: []string{"legacy"},
+		},
+		{
+			name:     "no policies",
+			data:     map[string]interface{}{},
+			policies: []string{},
+		},
+		{
+			name:     "ldap user groups",
+			data:     map[string]interface{}{"policies": []interface{}{"dev"}, "groups": "admins, engineers"},
+			policies: []string{"dev"},
+			groups:   []string{"admins", "engineers"},
+		},
+		{
+			name:     "okta user groups",
+			data:     map[string]interface{}{"groups": []interface{}{"admins"}},
+			policies: []string{},
+			groups:   []string{"admins"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role := parseAuthRole("name", tt.data)
+			if role.Name != "name" {
+				t.Errorf("name = %q, want %q", role.Name, "name")
+			}
+			if !reflect.DeepEqual(role.Policies, tt.policies) {
+				t.Errorf("policies = %v, want %v", role.Policies, tt.policies)
+			}
+			if !reflect.DeepEqual(role.Groups, tt.groups) {
+				t.Errorf("groups = %v, want %v", role.Groups, tt.groups)
+			}
+		})
+	}
+}
+
+func TestUserPolicies(t *testing.T) {
+	am := authMount{
+		Path:   "ldap/",
+		Type:   "ldap",
+		Groups: []authRole{{Name: "Admins", Policies: []string{"admin", "dev"}}, {Name: "other", Policies: []string{"other"}}},
+	}
+	user := authRole{Name: "alice", Policies: []string{"dev"}, Groups: []string{"admins"}}
+
+	want := []string{"dev", "admin"}
+	if got := am.userPolicies(user); !reflect.DeepEqual(got, want) {
+		t.Errorf("userPolicies = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileArg returns the value of the -config flag from the command line
+// arguments. The config file has to be loaded before the remaining flags are
+// parsed, so that flags given on the command line override its values.
+func configFileArg(args []string) string {
+	for idx, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if idx+1 < len(args) {
+			return args[idx+1]
+		}
+	}
+	return ""
+}
+
+// loadConfigFile reads scan settings from a YAML, JSON or HCL file, selected
+// by its extension. Keys are the JSON field names of clientConfig, and only
+// the keys present in the file are set.
+func (c *clientConfig) loadConfigFile(fileName string) error {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var values map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(fileName)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	case ".hcl":
+		err = hcl.Decode(&values, string(data))
+	default:
+		return fmt.Errorf("unsupported config file extension %q, expected .yaml, .yml, .json or .hcl", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", fileName, err)
+	}
+
+	// every format is normalized to JSON, so that a single set of field names
+	// and type checks applies regardless of the file format
+	normalized, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", fileName, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", fileName, err)
+	}
+
+	return nil
+}
+
+// loadEnv sets the settings that have an environment variable from those
+// variables that are set. It is applied after the config file, so that the
+// environment overrides the file, as it overrides the flag defaults.
+func (c *clientConfig) loadEnv() {
+	for key, value := range map[string]*string{
+		"VAULT_ADDR":            &c.Addr,
+		"VAULT_TOKEN":           &c.Token,
+		"VAULT_NAMESPACE":       &c.Namespace,
+		"VAULT_CACERT":          &c.CACert,
+		"VAULT_CAPATH":          &c.CAPath,
+		"VAULT_CLIENT_CERT":     &c.ClientCert,
+		"VAULT_CLIENT_KEY":      &c.ClientKey,
+		"VAULT_TLS_SERVER_NAME": &c.TLSServerName,
+	} {
+		if v := os.Getenv(key); v != "" {
+			*value = v
+		}
+	}
+	if v, err := strconv.ParseBool(os.Getenv("VAULT_SKIP_VERIFY")); err == nil {
+		c.TlsSkipVerify = v
+	}
+}
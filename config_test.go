@@ -0,0 +1,175 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	want := clientConfig{
+		Addr:              "https://vault.example.com:8200",
+		AuthMethod:        "approle",
+		MaxConcurrency:    5,
+		ListSecrets:       true,
+		IncludeNamespaces: []string{"tenants/*"},
+		ExcludeMountTypes: []string{"cubbyhole", "identity"},
+	}
+
+	tests := []struct {
+		name     string
+		fileName string
+		content  string
+	}{
+		{
+			name:     "yaml",
+			fileName: "config.yaml",
+			content: `addr: https://vault.example.com:8200
+authMethod: approle
+maxConcurrency: 5
+listSecrets: true
+includeNamespaces:
+  - tenants/*
+excludeMountTypes:
+  - cubbyhole
+  - identity
+`,
+		},
+		{
+			name:     "yml",
+			fileName: "config.yml",
+			content: `addr: https://vault.example.com:8200
+authMethod: approle
+maxConcurrency: 5
+listSecrets: true
+includeNamespaces: [tenants/*]
+excludeMountTypes: [cubbyhole, identity]
+`,
+		},
+		{
+			name:     "json",
+			fileName: "config.json",
+			content: `{
+  "addr": "https://vault.example.com:8200",
+  "authMethod": "approle",
+  "maxConcurrency": 5,
+  "listSecrets": true,
+  "includeNamespaces": ["tenants/*"],
+  "excludeMountTypes": ["cubbyhole", "identity"]
+}`,
+		},
+		{
+			name:     "hcl",
+			fileName: "config.hcl",
+			content: `addr              = "https://vault.example.com:8200"
+authMethod        = "approle"
+maxConcurrency    = 5
+listSecrets       = true
+includeNamespaces = ["tenants/*"]
+excludeMountTypes = ["cubbyhole", "identity"]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), tt.fileName)
+			if err := os.WriteFile(fileName, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			var c clientConfig
+			if err := c.loadConfigFile(fileName); err != nil {
+				t.Fatalf("loadConfigFile: %v", err)
+			}
+			if !reflect.DeepEqual(c, want) {
+				t.Errorf("loadConfigFile = %+v, want %+v", c, want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileKeepsUnsetValues(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(fileName, []byte("rateLimit: 20\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := clientConfig{Addr: "https://localhost:8200", RateLimit: 100, MaxConcurrency: 10}
+	if err := c.loadConfigFile(fileName); err != nil {
+		t.Fatalf("loadConfigFile: %v", err)
+	}
+	want := clientConfig{Addr: "https://localhost:8200", RateLimit: 20, MaxConcurrency: 10}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("loadConfigFile = %+v, want %+v", c, want)
+	}
+}
+
+func TestLoadConfigFileInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		content  string
+		errText  string
+	}{
+		{"yaml unknown key", "config.yaml", "address: https://vault:8200\n", `unknown field "address"`},
+		{"json unknown key", "config.json", `{"listSecret": true}`, `unknown field "listSecret"`},
+		{"hcl unknown key", "config.hcl", `include_namespaces = ["team-a"]`, `unknown field "include_namespaces"`},
+		{"wrong type", "config.yaml", "maxConcurrency: ten\n", "invalid config file"},
+		{"malformed yaml", "config.yaml", "addr: [\n", "error parsing config file"},
+		{"malformed hcl", "config.hcl", `addr = "https://vault:8200`, "error parsing config file"},
+		{"unsupported extension", "config.toml", `addr = "https://vault:8200"`, "unsupported config file extension"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), tt.fileName)
+			if err := os.WriteFile(fileName, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			var c clientConfig
+			err := c.loadConfigFile(fileName)
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("loadConfigFile = %v, want an error containing %q", err, tt.errText)
+			}
+		})
+	}
+}
+
+func TestLoadEnvOverridesConfigFile(t *testing.T) {
+	for _, key := range []string{"VAULT_TOKEN", "VAULT_NAMESPACE", "VAULT_CACERT", "VAULT_CAPATH", "VAULT_CLIENT_CERT", "VAULT_CLIENT_KEY", "VAULT_TLS_SERVER_NAME"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("VAULT_ADDR", "https://env:8200")
+	t.Setenv("VAULT_SKIP_VERIFY", "true")
+
+	c := clientConfig{Addr: "https://file:8200", Namespace: "admin", AuthMethod: "approle"}
+	c.loadEnv()
+
+	want := clientConfig{Addr: "https://env:8200", Namespace: "admin", AuthMethod: "approle", TlsSkipVerify: true}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("loadEnv = %+v, want %+v", c, want)
+	}
+}
+
+func TestConfigFileArg(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-config", "a.yaml"}, "a.yaml"},
+		{[]string{"--config=a.hcl", "-listSecrets"}, "a.hcl"},
+		{[]string{"-listSecrets", "-config=a.json"}, "a.json"},
+		{[]string{"-listSecrets"}, ""},
+		{[]string{"--", "-config", "a.yaml"}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := configFileArg(tt.args); got != tt.want {
+			t.Errorf("configFileArg(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
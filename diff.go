@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const diffHelpMessage = `
+The diff subcommand compares two inventory files written by previous scans and
+reports what changed between them, namespace by namespace: namespaces, auth
+mounts, auth method roles, certs, users and groups, secrets engines and their
+roles, policies, static secrets, entities and identity groups. Objects are
+matched on stable identifiers such as mount paths, role names, policy names,
+secret paths and entity IDs. No requests are made to Vault.`
+
+type inventoryDiff struct {
+	Old     string            `json:"old,omitempty"`
+	New     string            `json:"new,omitempty"`
+	Changes []inventoryChange `json:"changes,omitempty"`
+}
+
+// inventoryChange describes a single object that was added, removed or changed
+// between two inventories. Details lists the individual differences of a
+// changed object.
+type inventoryChange struct {
+	Namespace string   `json:"namespace,omitempty"`
+	Kind      string   `json:"kind,omitempty"`
+	Key       string   `json:"key,omitempty"`
+	Change    string   `json:"change,omitempty"`
+	Details   []string `json:"details,omitempty"`
+}
+
+const (
+	changeAdded   = "added"
+	changeRemoved = "removed"
+	changeChanged = "changed"
+)
+
+func runDiff(args []string) error {
+	var oldFile string
+	var newFile string
+	var outputFormat string
+
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.StringVar(&oldFile, "old", "", "Inventory file of the earlier scan")
+	fs.StringVar(&newFile, "new", "", "Inventory file of the later scan")
+	fs.StringVar(&outputFormat, "outputFormat", "text", "Output format. Options include text (human-readable) or json")
+	fs.Usage = func() {
+		fmt.Println(diffHelpMessage)
+		fmt.Fprintf(fs.Output(), "\nUsage of vault-auditor diff:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if oldFile == "" || newFile == "" {
+		return fmt.Errorf("missing required flags: old and new")
+	}
+
+	oldInventory, err := loadInventory(oldFile)
+	if err != nil {
+		return err
+	}
+	newInventory, err := loadInventory(newFile)
+	if err != nil {
+		return err
+	}
+
+	oldInventory.analyze()
+	newInventory.analyze()
+
+	d := diffInventories(oldInventory, newInventory)
+	d.Old = oldFile
+	d.New = newFile
+
+	switch outputFormat {
+	case "json":
+		var jsonBytes []byte
+		jsonBytes, err = json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return fmt.Errorf("json.MarshalIndent: %w", err)
+		}
+		_, err = fmt.Println(string(jsonBytes))
+	case "text":
+		_, err = fmt.Print(d.String())
+	default:
+		return fmt.Errorf("invalid output format: %s", outputFormat)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing to stdout: %w", err)
+	}
+
+	return nil
+}
+
+// String renders the diff in a human-readable form, one line per change
+// prefixed with +, - or ~, followed by the indented details of the change.
+func (d inventoryDiff) String() string {
+	var b strings.Builder
+
+	if len(d.Changes) == 0 {
+		b.WriteString("No changes.\n")
+		return b.String()
+	}
+
+	symbols := map[string]string{changeAdded: "+", changeRemoved: "-", changeChanged: "~"}
+	for _, change := range d.Changes {
+		fmt.Fprintf(&b, "%s [%s] %s %s\n", symbols[change.Change], change.Namespace, change.Kind, change.Key)
+		for _, detail := range change.Details {
+			fmt.Fprintf(&b, "    %s\n", detail)
+		}
+	}
+
+	return b.String()
+}
+
+// diffInventories compares two inventories namespace by namespace. The
+// contents of added or removed namespaces are not listed individually.
+func diffInventories(oldInventory, newInventory *vaultInventory) inventoryDiff {
+	var d inventoryDiff
+
+	oldNamespaces := map[string]namespaceInventory{}
+	for _, ns := range oldInventory.Namespaces {
+		oldNamespaces[ns.Name] = ns
+	}
+	newNamespaces := map[string]namespaceInventory{}
+	for _, ns := range newInventory.Namespaces {
+		newNamespaces[ns.Name] = ns
+	}
+
+	for _, change := range diffKeyed("", "namespace", oldNamespaces, newNamespaces, func(o, n namespaceInventory) []string {
+		return diffValue("parent", o.Parent, n.Parent)
+	}) {
+		change.Namespace = change.Key
+		d.Changes = append(d.Changes, change)
+	}
+
+	for name, oldNs := range oldNamespaces {
+		if newNs, ok := newNamespaces[name]; ok {
+			d.Changes = append(d.Changes, diffNamespace(oldNs, newNs)...)
+		}
+	}
+
+	sort.SliceStable(d.Changes, func(a, b int) bool {
+		x, y := d.Changes[a], d.Changes[b]
+		if x.Namespace != y.Namespace {
+			return x.Namespace < y.Namespace
+		}
+		if x.Kind != y.Kind {
+			return x.Kind < y.Kind
+		}
+		return x.Key < y.Key
+	})
+
+	return d
+}
+
+func diffNamespace(oldNs, newNs namespaceInventory) []inventoryChange {
+	var changes []inventoryChange
+
+	oldAuthMounts, oldAuthRoles := authMountsByKey(oldNs)
+	newAuthMounts, newAuthRoles := authMountsByKey(newNs)
+	changes = append(changes, diffKeyed(newNs.Name, "authMount", oldAuthMounts, newAuthMounts, func(o, n authMount) []string {
+		return diffValue("type", o.Type, n.Type)
+	})...)
+	changes = append(changes, diffKeyed(newNs.Name, "authRole", oldAuthRoles, newAuthRoles, func(o, n authRole) []string {
+		return append(diffStrings("policy", o.Policies, n.Policies), diffStrings("group", o.Groups, n.Groups)...)
+	})...)
+
+	oldEngines, oldEngineRoles, oldSecrets := secretsEnginesByKey(oldNs)
+	newEngines, newEngineRoles, newSecrets := secretsEnginesByKey(newNs)
+	changes = append(changes, diffKeyed(newNs.Name, "secretsEngine", oldEngines, newEngines, func(o, n secretsEngine) []string {
+		return append(diffValue("type", o.Type, n.Type), diffValue("version", o.Version, n.Version)...)
+	})...)
+	changes = append(changes, diffKeyed(newNs.Name, "engineRole", oldEngineRoles, newEngineRoles, func(o, n string) []string {
+		return nil
+	})...)
+	changes = append(changes, diffKeyed(newNs.Name, "secret", oldSecrets, newSecrets, func(o, n staticSecret) []string {
+		details := diffValue("version", string(o.CurrentVersion), string(n.CurrentVersion))
+		details = append(details, diffStrings("policy", o.Policies, n.Policies)...)
+		return append(details, diffStrings("role", o.Roles, n.Roles)...)
+	})...)
+
+	oldPolicies := map[string]policy{}
+	for _, p := range oldNs.Policies {
+		oldPolicies[p.Name] = p
+	}
+	newPolicies := map[string]policy{}
+	for _, p := range newNs.Policies {
+		newPolicies[p.Name] = p
+	}
+	changes = append(changes, diffKeyed(newNs.Name, "policy", oldPolicies, newPolicies, diffPolicyRules)...)
+
+	oldEntities := map[string]entity{}
+	for _, e := range oldNs.Entities {
+		oldEntities[e.ID] = e
+	}
+	newEntities := map[string]entity{}
+	for _, e := range newNs.Entities {
+		newEntities[e.ID] = e
+	}
+	changes = append(changes, diffKeyed(newNs.Name, "entity", oldEntities, newEntities, func(o, n entity) []string {
+		details := diffValue("name", o.Name, n.Name)
+		details = append(details, diffStrings("policy", entityPolicies(o), entityPolicies(n))...)
+		return append(details, diffStrings("alias", aliasNames(o.Aliases), aliasNames(n.Aliases))...)
+	})...)
+
+	oldGroups := map[string]identityGroup{}
+	for _, g := range oldNs.Groups {
+		oldGroups[g.ID] = g
+	}
+	newGroups := map[string]identityGroup{}
+	for _, g := range newNs.Groups {
+		newGroups[g.ID] = g
+	}
+	changes = append(changes, diffKeyed(newNs.Name, "identityGroup", oldGroups, newGroups, func(o, n identityGroup) []string {
+		details := diffValue("name", o.Name, n.Name)
+		details = append(details, diffStrings("policy", o.Policies, n.Policies)...)
+		details = append(details, diffStrings("member entity", o.MemberEntityIDs, n.MemberEntityIDs)...)
+		return append(details, diffStrings("member group", o.MemberGroupIDs, n.MemberGroupIDs)...)
+	})...)
+
+	return changes
+}
+
+// diffKeyed compares two sets of objects keyed on a stable identifier,
+// reporting added and removed keys, and objects for which compare returns any
+// differences.
+func diffKeyed[T any](namespace, kind string, oldItems, newItems map[string]T, compare func(o, n T) []string) []inventoryChange {
+	var changes []inventoryChange
+
+	for key, o := range oldItems {
+		n, ok := newItems[key]
+		if !ok {
+			changes = append(changes, inventoryChange{Namespace: namespace, Kind: kind, Key: key, Change: changeRemoved})
+			continue
+		}
+		if details := compare(o, n); len(details) > 0 {
+			changes = append(changes, inventoryChange{Namespace: namespace, Kind: kind, Key: key, Change: changeChanged, Details: details})
+		}
+	}
+	for key := range newItems {
+		if _, ok := oldItems[key]; !ok {
+			changes = append(changes, inventoryChange{Namespace: namespace, Kind: kind, Key: key, Change: changeAdded})
+		}
+	}
+
+	return changes
+}
+
+func diffValue(name, o, n string) []string {
+	if o == n {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: %q -> %q", name, o, n)}
+}
+
+func diffStrings(name string, o, n []string) []string {
+	var details []string
+
+	oldSet := map[string]bool{}
+	for _, item := range o {
+		oldSet[item] = true
+	}
+	newSet := map[string]bool{}
+	for _, item := range n {
+		newSet[item] = true
+	}
+
+	for _, item := range o {
+		if !newSet[item] {
+			details = append(details, fmt.Sprintf("- %s %s", name, item))
+		}
+	}
+	for _, item := range n {
+		if !oldSet[item] {
+			details = append(details, fmt.Sprintf("+ %s %s", name, item))
+		}
+	}
+	sort.Strings(details)
+
+	return details
+}
+
+// diffPolicyRules compares the rules of two versions of a policy, path by
+// path.
+func diffPolicyRules(o, n policy) []string {
+	describe := func(p policy) map[string]string {
+		rules := map[string]string{}
+		for _, rule := range p.Rules {
+			ruleJSON, _ := json.Marshal(rule)
+			if existing, ok := rules[rule.Path]; ok {
+				rules[rule.Path] = existing + " " + string(ruleJSON)
+			} else {
+				rules[rule.Path] = string(ruleJSON)
+			}
+		}
+		return rules
+	}
+
+	oldRules, newRules := describe(o), describe(n)
+	var details []string
+	for path, rule := range oldRules {
+		if newRule, ok := newRules[path]; !ok {
+			details = append(details, fmt.Sprintf("- path %q: %s", path, rule))
+		} else if newRule != rule {
+			details = append(details, fmt.Sprintf("~ path %q: %s -> %s", path, rule, newRule))
+		}
+	}
+	for path, rule := range newRules {
+		if _, ok := oldRules[path]; !ok {
+			details = append(details, fmt.Sprintf("+ path %q: %s", path, rule))
+		}
+	}
+	sort.Strings(details)
+
+	return details
+}
+
+// authMountsByKey indexes the auth mounts of a namespace by mount path, and
+// their roles, certs, users and groups by mount path, type and name.
+func authMountsByKey(ns namespaceInventory) (map[string]authMount, map[string]authRole) {
+	mounts := map[string]authMount{}
+	roles := map[string]authRole{}
+
+	for _, am := range ns.AuthMounts {
+		mounts[am.Path] = am
+		for _, kind := range []struct {
+			name  string
+			items []authRole
+		}{{"role", am.Roles}, {"cert", am.Certs}, {"user", am.Users}, {"group", am.Groups}} {
+			for _, item := range kind.items {
+				roles[am.Path+kind.name+"/"+item.Name] = item
+			}
+		}
+	}
+
+	return mounts, roles
+}
+
+// secretsEnginesByKey indexes the secrets engines of a namespace by mount path,
+// their roles by mount path and role name, and static secrets by path.
+func secretsEnginesByKey(ns namespaceInventory) (map[string]secretsEngine, map[string]string, map[string]staticSecret) {
+	engines := map[string]secretsEngine{}
+	roles := map[string]string{}
+	secrets := map[string]staticSecret{}
+
+	for _, engine := range ns.SecretsEngines {
+		engines[engine.Path] = engine
+		for _, role := range engine.Roles {
+			roles[engine.Path+role] = role
+		}
+		for _, secret := range engine.Secrets {
+			secrets[secret.Path] = secret
+		}
+	}
+
+	return engines, roles, secrets
+}
+
+// entityPolicies returns the effective policies of an entity, falling back to
+// its direct policies for inventories that were not analyzed.
+func entityPolicies(e entity) []string {
+	if len(e.EffectivePolicies) > 0 {
+		return e.EffectivePolicies
+	}
+	return e.Policies
+}
+
+func aliasNames(aliases []alias) []string {
+	names := make([]string, 0, len(aliases))
+	for _, a := range aliases {
+		names = append(names, a.MountPath+a.Name)
+	}
+	return names
+}
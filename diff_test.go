@@ -0,0 +1,137 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffInventories(t *testing.T) {
+	oldInventory := &vaultInventory{Namespaces: []namespaceInventory{{
+		Name: "root",
+		AuthMounts: []authMount{{
+			Path:  "approle/",
+			Type:  "approle",
+			Roles: []authRole{{Name: "app", Policies: []string{"reader"}}, {Name: "ci", Policies: []string{"writer"}}},
+		}},
+		SecretsEngines: []secretsEngine{{
+			Path:    "kv/",
+			Type:    "kv",
+			Version: "2",
+			Secrets: []staticSecret{
+				{Path: "kv/app", CurrentVersion: "1", Policies: []string{"reader"}},
+				{Path: "kv/old", CurrentVersion: "3"},
+			},
+		}},
+		Policies: []policy{
+			{Name: "reader", Rules: []policyRule{
+				{Path: "kv/data/*", Capabilities: []string{"read"}},
+				{Path: "kv/metadata/*", Capabilities: []string{"list"}},
+			}},
+			{Name: "legacy", Rules: []policyRule{{Path: "sys/*", Capabilities: []string{"sudo"}}}},
+		},
+		Entities: []entity{
+			{ID: "e1", Name: "alice", Policies: []string{"reader"}, Aliases: []alias{{Name: "alice", MountPath: "userpass/"}}},
+			{ID: "e2", Name: "bob"},
+		},
+	}}}
+
+	newInventory := &vaultInventory{Namespaces: []namespaceInventory{{
+		Name: "root",
+		AuthMounts: []authMount{{
+			Path:  "approle/",
+			Type:  "approle",
+			Roles: []authRole{{Name: "app", Policies: []string{"reader", "writer"}}, {Name: "deploy"}},
+		}},
+		SecretsEngines: []secretsEngine{{
+			Path:    "kv/",
+			Type:    "kv",
+			Version: "2",
+			Secrets: []staticSecret{
+				{Path: "kv/app", CurrentVersion: "2", Policies: []string{"reader"}},
+				{Path: "kv/new", CurrentVersion: "1"},
+			},
+		}},
+		Policies: []policy{
+			{Name: "reader", Rules: []policyRule{
+				{Path: "kv/data/*", Capabilities: []string{"read", "list"}},
+				{Path: "kv/data/app", Capabilities: []string{"deny"}},
+			}},
+			{Name: "writer", Rules: []policyRule{{Path: "kv/data/*", Capabilities: []string{"update"}}}},
+		},
+		Entities: []entity{
+			{ID: "e1", Name: "alice", Policies: []string{"writer"}, Aliases: []alias{{Name: "alice", MountPath: "userpass/"}}},
+			{ID: "e3", Name: "carol"},
+		},
+	}}}
+
+	want := []inventoryChange{
+		{Namespace: "root", Kind: "authRole", Key: "approle/role/app", Change: changeChanged, Details: []string{"+ policy writer"}},
+		{Namespace: "root", Kind: "authRole", Key: "approle/role/ci", Change: changeRemoved},
+		{Namespace: "root", Kind: "authRole", Key: "approle/role/deploy", Change: changeAdded},
+		{Namespace: "root", Kind: "entity", Key: "e1", Change: changeChanged, Details: []string{"+ policy writer", "- policy reader"}},
+		{Namespace: "root", Kind: "entity", Key: "e2", Change: changeRemoved},
+		{Namespace: "root", Kind: "entity", Key: "e3", Change: changeAdded},
+		{Namespace: "root", Kind: "policy", Key: "legacy", Change: changeRemoved},
+		{Namespace: "root", Kind: "policy", Key: "reader", Change: changeChanged, Details: []string{
+			`+ path "kv/data/app": {"path":"kv/data/app","capabilities":["deny"]}`,
+			`- path "kv/metadata/*": {"path":"kv/metadata/*","capabilities":["list"]}`,
+			`~ path "kv/data/*": {"path":"kv/data/*","capabilities":["read"]} -> {"path":"kv/data/*","capabilities":["read","list"]}`,
+		}},
+		{Namespace: "root", Kind: "policy", Key: "writer", Change: changeAdded},
+		{Namespace: "root", Kind: "secret", Key: "kv/app", Change: changeChanged, Details: []string{`version: "1" -> "2"`}},
+		{Namespace: "root", Kind: "secret", Key: "kv/new", Change: changeAdded},
+		{Namespace: "root", Kind: "secret", Key: "kv/old", Change: changeRemoved},
+	}
+
+	d := diffInventories(oldInventory, newInventory)
+	if !reflect.DeepEqual(d.Changes, want) {
+		t.Errorf("diffInventories =\n%#v\nwant\n%#v", d.Changes, want)
+	}
+}
+
+func TestDiffInventoriesNamespaces(t *testing.T) {
+	oldInventory := &vaultInventory{Namespaces: []namespaceInventory{
+		{Name: "root"},
+		{Name: "team-a", Parent: "root", Policies: []policy{{Name: "reader"}}},
+	}}
+	newInventory := &vaultInventory{Namespaces: []namespaceInventory{
+		{Name: "root"},
+		{Name: "team-b", Parent: "root", Policies: []policy{{Name: "reader"}}},
+	}}
+
+	// the contents of added and removed namespaces are not listed
+	want := []inventoryChange{
+		{Namespace: "team-a", Kind: "namespace", Key: "team-a", Change: changeRemoved},
+		{Namespace: "team-b", Kind: "namespace", Key: "team-b", Change: changeAdded},
+	}
+
+	d := diffInventories(oldInventory, newInventory)
+	if !reflect.DeepEqual(d.Changes, want) {
+		t.Errorf("diffInventories =\n%#v\nwant\n%#v", d.Changes, want)
+	}
+}
+
+func TestInventoryDiffString(t *testing.T) {
+	if got := (inventoryDiff{}).String(); got != "No changes.\n" {
+		t.Errorf("String of an empty diff = %q", got)
+	}
+
+	d := inventoryDiff{Changes: []inventoryChange{
+		{Namespace: "root", Kind: "policy", Key: "legacy", Change: changeRemoved},
+		{Namespace: "root", Kind: "policy", Key: "reader", Change: changeChanged, Details: []string{
+			`- path "kv/metadata/*": {"path":"kv/metadata/*","capabilities":["list"]}`,
+		}},
+		{Namespace: "root", Kind: "secret", Key: "kv/new", Change: changeAdded},
+	}}
+	want := strings.Join([]string{
+		"- [root] policy legacy",
+		"~ [root] policy reader",
+		`    - path "kv/metadata/*": {"path":"kv/metadata/*","capabilities":["list"]}`,
+		"+ [root] secret kv/new",
+		"",
+	}, "\n")
+	if got := d.String(); got != want {
+		t.Errorf("String =\n%s\nwant\n%s", got, want)
+	}
+}
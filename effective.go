@@ -0,0 +1,235 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/czembower/vault-auditor/utils"
+)
+
+// entityPermissions describes everything an entity can reach through the
+// combination of its own policies, the policies of its identity groups, and
+// the policies of the auth roles, users and certs its aliases log in through.
+type entityPermissions struct {
+	Namespace  string         `json:"namespace,omitempty"`
+	EntityID   string         `json:"entityId,omitempty"`
+	EntityName string         `json:"entityName,omitempty"`
+	Policies   []policySource `json:"policies,omitempty"`
+	Paths      []pathGrant    `json:"paths,omitempty"`
+	Secrets    []secretGrant  `json:"secrets,omitempty"`
+	// UnresolvedAliases lists the aliases, as mount path and alias name,
+	// whose auth role, user or cert could not be determined, so that any
+	// policies they log in with are missing from Policies
+	UnresolvedAliases []string `json:"unresolvedAliases,omitempty"`
+}
+
+// policySource records a policy attached to an entity and where it came from:
+// "entity", an identity group, or an auth method role, user or cert.
+type policySource struct {
+	Policy string `json:"policy,omitempty"`
+	Source string `json:"source,omitempty"`
+}
+
+type pathGrant struct {
+	Path         string   `json:"path,omitempty"`
+	Policy       string   `json:"policy,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// secretGrant holds the combined capabilities of an entity's full policy set
+// on a static secret.
+type secretGrant struct {
+	Path string `json:"path,omitempty"`
+	secretAccess
+}
+
+// mapEffectivePermissions computes the effective permissions of every entity
+// in the inventory. The report grows with the number of entities times the
+// number of static secrets, so it is only computed on request.
+func (i *vaultInventory) mapEffectivePermissions() {
+	i.EffectivePermissions = nil
+	groups := i.groupIndex()
+	for nsIdx := range i.Namespaces {
+		ns := &i.Namespaces[nsIdx]
+		for _, e := range ns.Entities {
+			i.EffectivePermissions = append(i.EffectivePermissions, i.entityPermissions(ns, e, groups))
+		}
+	}
+}
+
+func (i *vaultInventory) entityPermissions(ns *namespaceInventory, e entity, groups *groupIndex) entityPermissions {
+	permissions := entityPermissions{
+		Namespace:  ns.Name,
+		EntityID:   e.ID,
+		EntityName: e.Name,
+	}
+	permissions.Policies, permissions.UnresolvedAliases = i.entityPolicySources(ns, e, groups)
+
+	var rules []scopedRule
+	seen := map[string]bool{}
+	for _, source := range permissions.Policies {
+		if seen[source.Policy] {
+			continue
+		}
+		seen[source.Policy] = true
+
+		policyNamespace, p := i.findPolicy(ns.Name, source.Policy)
+		if p == nil {
+			continue
+		}
+		for _, rule := range policyRules(policyNamespace, *p) {
+			rules = append(rules, rule)
+			if !utils.StringInSlice("deny", rule.Rule.Capabilities) {
+				permissions.Paths = append(permissions.Paths, pathGrant{Path: rule.pattern(), Policy: source.Policy, Capabilities: rule.Rule.Capabilities})
+			}
+		}
+	}
+	sort.Slice(permissions.Paths, func(a, b int) bool {
+		return permissions.Paths[a].Path < permissions.Paths[b].Path
+	})
+
+	for nsIdx := range i.Namespaces {
+		secretNamespace := &i.Namespaces[nsIdx]
+		if secretNamespace.Name != ns.Name && !isAncestorNamespace(ns.Name, secretNamespace.Name) {
+			continue
+		}
+		for seIdx := range secretNamespace.SecretsEngines {
+			engine := &secretNamespace.SecretsEngines[seIdx]
+			for _, secret := range engine.Secrets {
+				access, matched := resolveSecretAccess(rules, kvAPIPaths(secretNamespace.Name, engine, secret.Path))
+				if matched && access.grantsAny() {
+					permissions.Secrets = append(permissions.Secrets, secretGrant{Path: secret.Path, secretAccess: access})
+				}
+			}
+		}
+	}
+
+	return permissions
+}
+
+// entityPolicySources returns every policy that applies to the entity along
+// with its source, and the aliases whose auth role, user or cert could not be
+// determined.
+func (i *vaultInventory) entityPolicySources(ns *namespaceInventory, e entity, groups *groupIndex) ([]policySource, []string) {
+	var sources []policySource
+	var unresolved []string
+
+	for _, policy := range e.Policies {
+		sources = append(sources, policySource{Policy: policy, Source: "entity"})
+	}
+	memberOf := groups.entityGroups(e)
+	groupIDs := make([]string, 0, len(memberOf))
+	for id := range memberOf {
+		groupIDs = append(groupIDs, id)
+	}
+	sort.Strings(groupIDs)
+	for _, groupID := range groupIDs {
+		g, ok := groups.groups[groupID]
+		if !ok {
+			continue
+		}
+		for _, policy := range g.group.Policies {
+			if g.namespace != ns.Name {
+				policy = policy + " (" + g.namespace + ")"
+			}
+			sources = append(sources, policySource{Policy: policy, Source: "identity/group/name/" + g.group.Name})
+		}
+	}
+	for _, a := range e.Aliases {
+		aliasSources, resolved := ns.aliasPolicySources(a)
+		sources = append(sources, aliasSources...)
+		if !resolved {
+			unresolved = append(unresolved, a.MountPath+a.Name)
+		}
+	}
+
+	return sources, unresolved
+}
+
+// policySetRules returns the combined rules of the named policies, resolved
+// relative to the given namespace as described for findPolicy.
+func (i *vaultInventory) policySetRules(namespace string, names []string) []scopedRule {
+	var rules []scopedRule
+	for _, name := range names {
+		policyNamespace, p := i.findPolicy(namespace, name)
+		if p != nil {
+			rules = append(rules, policyRules(policyNamespace, *p)...)
+		}
+	}
+	return rules
+}
+
+// aliasPolicySources returns the policies granted by the auth role, user or
+// cert an entity alias logs in through, and whether that role, user or cert
+// could be determined. Users are matched on the alias name, along with the
+// auth method groups they are configured as members of. Roles and certs can
+// only be matched when the auth method records their name in the alias
+// metadata, as approle does with role_name; most other methods, such as
+// kubernetes and jwt, do not, and their aliases are reported as unresolved.
+func (ns *namespaceInventory) aliasPolicySources(a alias) ([]policySource, bool) {
+	var sources []policySource
+	resolved := false
+
+	add := func(mount authMount, kind string, items []authRole, name string) {
+		if name == "" {
+			return
+		}
+		for _, item := range items {
+			if !strings.EqualFold(item.Name, name) {
+				continue
+			}
+			if kind != "groups" {
+				resolved = true
+			}
+			for _, policy := range item.Policies {
+				sources = append(sources, policySource{Policy: policy, Source: "auth/" + mount.Path + kind + "/" + item.Name})
+			}
+		}
+	}
+
+	for _, am := range ns.AuthMounts {
+		if "auth/"+am.Path != a.MountPath {
+			continue
+		}
+		add(am, "users", am.Users, a.Name)
+		for _, user := range am.Users {
+			if !strings.EqualFold(user.Name, a.Name) {
+				continue
+			}
+			for _, group := range user.Groups {
+				add(am, "groups", am.Groups, group)
+			}
+		}
+		rolePath := "role"
+		if utils.StringInSlice(am.Type, strings.Split(authMethodsWithRoles, ", ")) {
+			rolePath = "roles"
+		}
+		add(am, rolePath, am.Roles, a.Metadata["role"])
+		add(am, rolePath, am.Roles, a.Metadata["role_name"])
+		add(am, "certs", am.Certs, a.Metadata["cert_name"])
+	}
+
+	return sources, resolved
+}
+
+// findPolicy resolves a policy name as it appears on an entity in the given
+// namespace. Names suffixed with a namespace in parentheses refer to a policy
+// defined in that namespace.
+func (i *vaultInventory) findPolicy(namespace, name string) (string, *policy) {
+	if idx := strings.LastIndex(name, " ("); idx >= 0 && strings.HasSuffix(name, ")") {
+		namespace, name = name[idx+2:len(name)-1], name[:idx]
+	}
+
+	for nsIdx := range i.Namespaces {
+		if i.Namespaces[nsIdx].Name != namespace {
+			continue
+		}
+		for pIdx := range i.Namespaces[nsIdx].Policies {
+			if i.Namespaces[nsIdx].Policies[pIdx].Name == name {
+				return namespace, &i.Namespaces[nsIdx].Policies[pIdx]
+			}
+		}
+	}
+
+	return namespace, nil
+}
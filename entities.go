@@ -2,23 +2,28 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/czembower/vault-auditor/utils"
 )
 
 type entity struct {
-	ID       string   `json:"id,omitempty"`
-	Name     string   `json:"name,omitempty"`
-	Policies []string `json:"policies,omitempty"`
-	Aliases  []alias  `json:"aliases,omitempty"`
+	ID                string   `json:"id,omitempty"`
+	Name              string   `json:"name,omitempty"`
+	Policies          []string `json:"policies,omitempty"`
+	GroupIDs          []string `json:"groupIds,omitempty"`
+	GroupPolicies     []string `json:"groupPolicies,omitempty"`
+	EffectivePolicies []string `json:"effectivePolicies,omitempty"`
+	Aliases           []alias  `json:"aliases,omitempty"`
 }
 
 type alias struct {
-	ID        string `json:"id,omitempty"`
-	Name      string `json:"name,omitempty"`
-	MountPath string `json:"mountPath,omitempty"`
-	MountType string `json:"mountType,omitempty"`
+	ID        string            `json:"id,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	MountPath string            `json:"mountPath,omitempty"`
+	MountType string            `json:"mountType,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
 }
 
 func (ns *namespaceInventory) scanEntities(c *clientConfig) {
@@ -38,6 +43,7 @@ func (ns *namespaceInventory) scanEntities(c *clientConfig) {
 	}
 
 	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
 	sem := make(chan struct{}, c.MaxConcurrency)
 	for _, data := range keys {
 		wg.Add(1)
@@ -45,12 +51,13 @@ func (ns *namespaceInventory) scanEntities(c *clientConfig) {
 		go func() {
 			defer wg.Done()
 			defer func() { <-sem }()
-			ns.getEntity(c, data.(string), path)
+			ns.getEntity(c, data.(string), path, &mu)
 		}()
 	}
+	wg.Wait()
 }
 
-func (ns *namespaceInventory) getEntity(c *clientConfig, id string, path string) {
+func (ns *namespaceInventory) getEntity(c *clientConfig, id string, path string, mu *sync.Mutex) {
 	var e entity
 	e.ID = id
 
@@ -76,6 +83,14 @@ func (ns *namespaceInventory) getEntity(c *clientConfig, id string, path string)
 		}
 	}
 
+	if groupIDs, ok := entityData.Data["group_ids"].([]interface{}); ok {
+		for _, groupID := range groupIDs {
+			if groupIDStr, ok := groupID.(string); ok {
+				e.GroupIDs = append(e.GroupIDs, groupIDStr)
+			}
+		}
+	}
+
 	if aliases, ok := entityData.Data["aliases"].([]interface{}); ok {
 		e.Aliases = make([]alias, 0, len(aliases))
 		for _, aliasData := range aliases {
@@ -91,11 +106,21 @@ func (ns *namespaceInventory) getEntity(c *clientConfig, id string, path string)
 				MountPath: utils.GetStringFromMap(aliasMap, "mount_path"),
 				MountType: utils.GetStringFromMap(aliasMap, "mount_type"),
 			}
+			if metadata, ok := aliasMap["metadata"].(map[string]interface{}); ok && len(metadata) > 0 {
+				a.Metadata = make(map[string]string, len(metadata))
+				for k := range metadata {
+					a.Metadata[k] = utils.GetStringFromMap(metadata, k)
+				}
+			}
+			// aliases on filtered auth mounts are dropped, but the entity
+			// and its policies are kept
+			if !c.includeAuthMount(ns.Name, strings.TrimPrefix(a.MountPath, "auth/"), a.MountType) {
+				continue
+			}
 			e.Aliases = append(e.Aliases, a)
 		}
 	}
 
-	mu := sync.Mutex{}
 	mu.Lock()
 	ns.Entities = append(ns.Entities, e)
 	mu.Unlock()
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/czembower/vault-auditor/utils"
+	"github.com/ryanuber/go-glob"
+)
+
+// stringList is a repeatable flag collecting each of its values. Values from
+// the config file are replaced, rather than extended, once the flag is given
+// on the command line.
+type stringList struct {
+	values *[]string
+	set    bool
+}
+
+func (l *stringList) String() string {
+	if l == nil || l.values == nil {
+		return ""
+	}
+	return strings.Join(*l.values, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	if !l.set {
+		*l.values = nil
+		l.set = true
+	}
+	*l.values = append(*l.values, value)
+	return nil
+}
+
+// includeNamespace reports whether the namespace passes the configured
+// namespace filters.
+func (c *clientConfig) includeNamespace(namespace string) bool {
+	return filterMatches(c.IncludeNamespaces, c.ExcludeNamespaces, namespace)
+}
+
+// filterNamespaces splits a list of namespaces into those passing the
+// namespace filters, which are scanned in full, and the ancestors of those
+// that do not pass them, whose policies are still needed to match secrets in
+// their descendants. Other namespaces are left out.
+func (c *clientConfig) filterNamespaces(namespaces []string) (included, policiesOnly []string) {
+	for _, namespace := range namespaces {
+		if c.includeNamespace(namespace) {
+			included = append(included, namespace)
+		}
+	}
+	for _, namespace := range namespaces {
+		if c.includeNamespace(namespace) {
+			continue
+		}
+		for _, child := range included {
+			if isAncestorNamespace(namespace, child) {
+				policiesOnly = append(policiesOnly, namespace)
+				break
+			}
+		}
+	}
+	return included, policiesOnly
+}
+
+// includeAuthMount reports whether an auth mount passes the configured mount,
+// mount type and auth mount filters. The generic mount filters see the mount
+// under its API path, e.g. "auth/userpass", while the auth mount filters see
+// it as listed by sys/auth, e.g. "userpass".
+func (c *clientConfig) includeAuthMount(namespace, path, mountType string) bool {
+	return c.includeMount(namespace, "auth/"+path, mountType) &&
+		pathFilterMatches(c.IncludeAuthMounts, c.ExcludeAuthMounts, namespace, strings.TrimSuffix(path, "/"))
+}
+
+// includeSecretsEngine reports whether a secrets engine passes the configured
+// mount, mount type and secrets engine filters.
+func (c *clientConfig) includeSecretsEngine(namespace, path, mountType string) bool {
+	return c.includeMount(namespace, path, mountType) &&
+		pathFilterMatches(c.IncludeSecretsEngines, c.ExcludeSecretsEngines, namespace, strings.TrimSuffix(path, "/"))
+}
+
+// includeMount reports whether a mount passes the configured mount path and
+// mount type filters. Mount path patterns are matched against the API path of
+// the mount relative to its namespace, e.g. "kv" or "auth/userpass", as well
+// as the full path, e.g. "team-a/kv".
+func (c *clientConfig) includeMount(namespace, path, mountType string) bool {
+	return pathFilterMatches(c.IncludeMounts, c.ExcludeMounts, namespace, strings.TrimSuffix(path, "/")) &&
+		filterMatches(c.IncludeMountTypes, c.ExcludeMountTypes, mountType)
+}
+
+// includeSecret reports whether the logical path of a KV secret, e.g.
+// "team-a/kv/app/config", passes the configured secret path filters.
+func (c *clientConfig) includeSecret(namespace, secretPath string) bool {
+	path := strings.TrimPrefix(secretPath, utils.SetNamespacePath(namespace))
+	return pathFilterMatches(c.IncludeSecretPaths, c.ExcludeSecretPaths, namespace, path)
+}
+
+// includeSecretDir reports whether a KV directory, given by its logical path
+// ending in "/", may contain secrets that pass the secret path filters. A
+// directory is skipped when it matches an exclude pattern, e.g. "kv/tmp/*",
+// or when none of the include patterns can match anything beneath it.
+func (c *clientConfig) includeSecretDir(namespace, dirPath string) bool {
+	path := strings.TrimPrefix(dirPath, utils.SetNamespacePath(namespace))
+	fullPath := utils.SetNamespacePath(namespace) + path
+
+	if globMatchesAny(c.ExcludeSecretPaths, path) || globMatchesAny(c.ExcludeSecretPaths, fullPath) {
+		return false
+	}
+	if len(c.IncludeSecretPaths) == 0 {
+		return true
+	}
+
+	for _, pattern := range c.IncludeSecretPaths {
+		// only the literal part of the pattern before its first glob can be
+		// compared against a directory whose contents are not yet known
+		prefix, _, _ := strings.Cut(strings.Trim(pattern, "/"), "*")
+		for _, p := range []string{path, fullPath} {
+			if strings.HasPrefix(p, prefix) || strings.HasPrefix(prefix, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pathFilterMatches applies include and exclude patterns to a path relative to
+// its namespace. Patterns may match either the relative path or the full path
+// prefixed with the namespace.
+func pathFilterMatches(include, exclude []string, namespace, path string) bool {
+	fullPath := utils.SetNamespacePath(namespace) + path
+
+	if len(include) > 0 && !globMatchesAny(include, path) && !globMatchesAny(include, fullPath) {
+		return false
+	}
+	return !globMatchesAny(exclude, path) && !globMatchesAny(exclude, fullPath)
+}
+
+// filterMatches reports whether value matches at least one include pattern,
+// or there are none, and does not match any exclude pattern.
+func filterMatches(include, exclude []string, value string) bool {
+	if len(include) > 0 && !globMatchesAny(include, value) {
+		return false
+	}
+	return !globMatchesAny(exclude, value)
+}
+
+// globMatchesAny reports whether value matches any of the given patterns, in
+// which * matches any sequence of characters, including "/".
+func globMatchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if glob.Glob(strings.Trim(pattern, "/"), value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterNamespaces(t *testing.T) {
+	namespaces := []string{"root", "tenants", "tenants/team-a", "tenants/team-a/dev", "tenants/team-b", "other"}
+
+	tests := []struct {
+		name         string
+		include      []string
+		exclude      []string
+		included     []string
+		policiesOnly []string
+	}{
+		{
+			name:     "no filters",
+			included: namespaces,
+		},
+		{
+			name:         "include children",
+			include:      []string{"tenants/*"},
+			included:     []string{"tenants/team-a", "tenants/team-a/dev", "tenants/team-b"},
+			policiesOnly: []string{"root", "tenants"},
+		},
+		{
+			name:         "exclude parent",
+			include:      []string{"tenants/team-a/dev"},
+			included:     []string{"tenants/team-a/dev"},
+			policiesOnly: []string{"root", "tenants", "tenants/team-a"},
+		},
+		{
+			name:     "exclude leaf",
+			exclude:  []string{"tenants/team-b", "other"},
+			included: []string{"root", "tenants", "tenants/team-a", "tenants/team-a/dev"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := clientConfig{IncludeNamespaces: tt.include, ExcludeNamespaces: tt.exclude}
+			included, policiesOnly := c.filterNamespaces(namespaces)
+			if !reflect.DeepEqual(included, tt.included) {
+				t.Errorf("included = %v, want %v", included, tt.included)
+			}
+			if !reflect.DeepEqual(policiesOnly, tt.policiesOnly) {
+				t.Errorf("policiesOnly = %v, want %v", policiesOnly, tt.policiesOnly)
+			}
+		})
+	}
+}
+
+func TestAncestorPoliciesOfIncludedNamespace(t *testing.T) {
+	i := &vaultInventory{Namespaces: []namespaceInventory{
+		{
+			Name:         "root",
+			PoliciesOnly: true,
+			Policies: []policy{{Name: "reader", Rules: []policyRule{
+				{Path: "tenants/team-a/kv/data/*", Capabilities: []string{"read"}},
+			}}},
+		},
+		{
+			Name:         "tenants",
+			Parent:       "root",
+			PoliciesOnly: true,
+			Policies: []policy{{Name: "writer", Rules: []policyRule{
+				{Path: "team-a/kv/data/app", Capabilities: []string{"create", "update"}},
+			}}},
+		},
+		{
+			Name:   "tenants/team-a",
+			Parent: "tenants",
+			SecretsEngines: []secretsEngine{{
+				Path:    "kv/",
+				Type:    "kv",
+				Version: "2",
+				Secrets: []staticSecret{{Path: "tenants/team-a/kv/app"}},
+			}},
+		},
+	}}
+
+	i.analyze()
+
+	secret := i.Namespaces[2].SecretsEngines[0].Secrets[0]
+	if want := []string{"reader (root)"}; !reflect.DeepEqual(secret.ReadPolicies, want) {
+		t.Errorf("ReadPolicies = %v, want %v", secret.ReadPolicies, want)
+	}
+	if want := []string{"writer (tenants)"}; !reflect.DeepEqual(secret.WritePolicies, want) {
+		t.Errorf("WritePolicies = %v, want %v", secret.WritePolicies, want)
+	}
+}
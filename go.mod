@@ -4,17 +4,31 @@ go 1.23.1
 
 require (
 	github.com/czembower/vault-auditor/utils v0.0.0-20240913182445-06916ea6e030
-	github.com/hashicorp/go-secure-stdlib/strutil v0.1.2
+	github.com/go-sql-driver/mysql v1.8.1
+	github.com/hashicorp/hcl v1.0.0
 	github.com/hashicorp/vault-client-go v0.4.3
 	github.com/lib/pq v1.10.9
+	github.com/ryanuber/go-glob v1.0.0
 	golang.org/x/time v0.0.0-20220922220347-f3bd1da661af
+	gopkg.in/yaml.v3 v3.0.1
+	modernc.org/sqlite v1.37.1
 )
 
 require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
 	github.com/hashicorp/go-cleanhttp v0.5.2 // indirect
 	github.com/hashicorp/go-retryablehttp v0.7.1 // indirect
 	github.com/hashicorp/go-rootcerts v1.0.2 // indirect
+	github.com/hashicorp/go-secure-stdlib/strutil v0.1.2 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
-	github.com/ryanuber/go-glob v1.0.0 // indirect
-	golang.org/x/sys v0.4.0 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/exp v0.0.0-20250408133849-7e4ce0ab07d0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
+	modernc.org/libc v1.65.7 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
 )
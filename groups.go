@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/czembower/vault-auditor/utils"
+)
+
+type identityGroup struct {
+	ID              string   `json:"id,omitempty"`
+	Name            string   `json:"name,omitempty"`
+	Type            string   `json:"type,omitempty"`
+	Policies        []string `json:"policies,omitempty"`
+	MemberEntityIDs []string `json:"memberEntityIds,omitempty"`
+	MemberGroupIDs  []string `json:"memberGroupIds,omitempty"`
+	ParentGroupIDs  []string `json:"parentGroupIds,omitempty"`
+	Alias           *alias   `json:"alias,omitempty"`
+}
+
+func (ns *namespaceInventory) scanGroups(c *clientConfig) {
+	namespacePath := utils.SetNamespacePath(ns.Name)
+	path := namespacePath + "identity/group/id"
+
+	resp, err := c.Client.List(c.Ctx, path)
+	if err != nil {
+		utils.AppendError(fmt.Sprintf("error listing path %s: %v", path, err), &ns.Errors)
+		return
+	}
+
+	keys, ok := resp.Data["keys"].([]interface{})
+	if !ok {
+		utils.AppendError(fmt.Sprintf("invalid data type for keys at path %s", path), &ns.Errors)
+		return
+	}
+
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	sem := make(chan struct{}, c.MaxConcurrency)
+	for _, data := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ns.getGroup(c, data.(string), path, &mu)
+		}()
+	}
+	wg.Wait()
+}
+
+func (ns *namespaceInventory) getGroup(c *clientConfig, id string, path string, mu *sync.Mutex) {
+	var g identityGroup
+	g.ID = id
+
+	groupPath := path + "/" + g.ID
+	groupData, err := c.Client.Read(c.Ctx, groupPath)
+	if err != nil {
+		mu.Lock()
+		utils.AppendError(fmt.Sprintf("error reading path %s: %v", groupPath, err), &ns.Errors)
+		mu.Unlock()
+		return
+	}
+
+	g.Name = utils.GetStringFromMap(groupData.Data, "name")
+	g.Type = utils.GetStringFromMap(groupData.Data, "type")
+	g.Policies = getStringsFromMap(groupData.Data, "policies")
+	g.MemberEntityIDs = getStringsFromMap(groupData.Data, "member_entity_ids")
+	g.MemberGroupIDs = getStringsFromMap(groupData.Data, "member_group_ids")
+	g.ParentGroupIDs = getStringsFromMap(groupData.Data, "parent_group_ids")
+
+	// external groups are linked to a group in an external auth provider
+	// through a group alias
+	if aliasMap, ok := groupData.Data["alias"].(map[string]interface{}); ok && len(aliasMap) > 0 {
+		g.Alias = &alias{
+			ID:        utils.GetStringFromMap(aliasMap, "id"),
+			Name:      utils.GetStringFromMap(aliasMap, "name"),
+			MountPath: utils.GetStringFromMap(aliasMap, "mount_path"),
+			MountType: utils.GetStringFromMap(aliasMap, "mount_type"),
+		}
+	}
+
+	mu.Lock()
+	ns.Groups = append(ns.Groups, g)
+	mu.Unlock()
+}
+
+// namespacedGroup is an identity group along with the namespace it belongs to.
+type namespacedGroup struct {
+	namespace string
+	group     *identityGroup
+}
+
+// groupIndex holds the lookups needed to resolve group membership, built once
+// per inventory: the identity groups of every namespace by ID, the groups
+// listing each entity as a member, and the groups containing each group as a
+// subgroup.
+type groupIndex struct {
+	groups       map[string]namespacedGroup
+	directGroups map[string][]string
+	parents      map[string][]string
+}
+
+func (i *vaultInventory) groupIndex() *groupIndex {
+	idx := &groupIndex{
+		groups:       map[string]namespacedGroup{},
+		directGroups: map[string][]string{},
+		parents:      map[string][]string{},
+	}
+	for nsIdx := range i.Namespaces {
+		for gIdx := range i.Namespaces[nsIdx].Groups {
+			g := &i.Namespaces[nsIdx].Groups[gIdx]
+			idx.groups[g.ID] = namespacedGroup{namespace: i.Namespaces[nsIdx].Name, group: g}
+			for _, entityID := range g.MemberEntityIDs {
+				idx.directGroups[entityID] = append(idx.directGroups[entityID], g.ID)
+			}
+			for _, memberGroupID := range g.MemberGroupIDs {
+				idx.parents[memberGroupID] = append(idx.parents[memberGroupID], g.ID)
+			}
+		}
+	}
+	return idx
+}
+
+// expandGroupMembership adds to memberOf every group that contains one of its
+// groups as a subgroup, transitively, as membership of a group implies
+// membership of every group that contains it.
+func (idx *groupIndex) expandGroupMembership(memberOf map[string]bool) {
+	queue := make([]string, 0, len(memberOf))
+	for id := range memberOf {
+		queue = append(queue, id)
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, parentID := range idx.parents[id] {
+			if !memberOf[parentID] {
+				memberOf[parentID] = true
+				queue = append(queue, parentID)
+			}
+		}
+	}
+}
+
+// entityGroups returns the IDs of the groups the entity belongs to, either
+// directly or through membership of a subgroup, in any namespace.
+func (idx *groupIndex) entityGroups(e entity) map[string]bool {
+	memberOf := map[string]bool{}
+	for _, id := range e.GroupIDs {
+		memberOf[id] = true
+	}
+	for _, id := range idx.directGroups[e.ID] {
+		memberOf[id] = true
+	}
+	idx.expandGroupMembership(memberOf)
+	return memberOf
+}
+
+// groupPolicies returns the policies of the given groups as they apply to a
+// member in the given namespace: policies of groups in a different namespace
+// are suffixed with that namespace.
+func (idx *groupIndex) groupPolicies(memberOf map[string]bool, namespace string) []string {
+	policies := []string{}
+	for id := range memberOf {
+		g, ok := idx.groups[id]
+		if !ok {
+			continue
+		}
+		for _, policy := range g.group.Policies {
+			if g.namespace != namespace {
+				policy = policy + " (" + g.namespace + ")"
+			}
+			if !utils.StringInSlice(policy, policies) {
+				policies = append(policies, policy)
+			}
+		}
+	}
+	sort.Strings(policies)
+	return policies
+}
+
+// resolveGroupPolicies determines the identity groups every entity belongs to,
+// either directly or through membership of a subgroup, and folds the policies
+// of those groups into the entity's effective policy set. Policies of groups
+// in a different namespace than the entity are suffixed with that namespace.
+func (i *vaultInventory) resolveGroupPolicies() {
+	groups := i.groupIndex()
+
+	for nsIdx := range i.Namespaces {
+		ns := &i.Namespaces[nsIdx]
+		for eIdx := range ns.Entities {
+			e := &ns.Entities[eIdx]
+
+			memberOf := groups.entityGroups(*e)
+			e.GroupIDs = []string{}
+			for id := range memberOf {
+				e.GroupIDs = append(e.GroupIDs, id)
+			}
+			sort.Strings(e.GroupIDs)
+			e.GroupPolicies = groups.groupPolicies(memberOf, ns.Name)
+
+			e.EffectivePolicies = append([]string{}, e.Policies...)
+			for _, policy := range e.GroupPolicies {
+				if !utils.StringInSlice(policy, e.EffectivePolicies) {
+					e.EffectivePolicies = append(e.EffectivePolicies, policy)
+				}
+			}
+		}
+	}
+}
+
+// getStringsFromMap returns the string elements of the list stored under key,
+// or nil if the key is missing or not a list.
+func getStringsFromMap(m map[string]interface{}, key string) []string {
+	list, ok := m[key].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		if value, ok := item.(string); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
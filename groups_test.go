@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveGroupPolicies(t *testing.T) {
+	i := &vaultInventory{Namespaces: []namespaceInventory{
+		{
+			Name: "root",
+			Groups: []identityGroup{
+				// platform contains ops, which contains oncall
+				{ID: "g-platform", Name: "platform", Policies: []string{"platform"}, MemberGroupIDs: []string{"g-ops"}},
+			},
+		},
+		{
+			Name:   "team-a",
+			Parent: "root",
+			Entities: []entity{
+				{ID: "e-alice", Name: "alice", Policies: []string{"base"}},
+				{ID: "e-bob", Name: "bob", Policies: []string{"base"}, GroupIDs: []string{"g-dev"}},
+				{ID: "e-carol", Name: "carol"},
+			},
+			Groups: []identityGroup{
+				{ID: "g-ops", Name: "ops", Policies: []string{"ops"}, MemberGroupIDs: []string{"g-oncall"}},
+				{ID: "g-oncall", Name: "oncall", Policies: []string{"oncall", "base"}, MemberEntityIDs: []string{"e-alice"}},
+				{ID: "g-dev", Name: "dev", Policies: []string{"dev"}},
+			},
+		},
+	}}
+
+	i.resolveGroupPolicies()
+
+	tests := []struct {
+		entity            entity
+		groupIDs          []string
+		groupPolicies     []string
+		effectivePolicies []string
+	}{
+		{
+			entity:            i.Namespaces[1].Entities[0],
+			groupIDs:          []string{"g-oncall", "g-ops", "g-platform"},
+			groupPolicies:     []string{"base", "oncall", "ops", "platform (root)"},
+			effectivePolicies: []string{"base", "oncall", "ops", "platform (root)"},
+		},
+		{
+			entity:            i.Namespaces[1].Entities[1],
+			groupIDs:          []string{"g-dev"},
+			groupPolicies:     []string{"dev"},
+			effectivePolicies: []string{"base", "dev"},
+		},
+		{
+			entity:            i.Namespaces[1].Entities[2],
+			groupIDs:          []string{},
+			groupPolicies:     []string{},
+			effectivePolicies: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.entity.GroupIDs, tt.groupIDs) {
+			t.Errorf("%s: GroupIDs = %v, want %v", tt.entity.Name, tt.entity.GroupIDs, tt.groupIDs)
+		}
+		if !reflect.DeepEqual(tt.entity.GroupPolicies, tt.groupPolicies) {
+			t.Errorf("%s: GroupPolicies = %v, want %v", tt.entity.Name, tt.entity.GroupPolicies, tt.groupPolicies)
+		}
+		if !reflect.DeepEqual(tt.entity.EffectivePolicies, tt.effectivePolicies) {
+			t.Errorf("%s: EffectivePolicies = %v, want %v", tt.entity.Name, tt.entity.EffectivePolicies, tt.effectivePolicies)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault-client-go"
+	"github.com/hashicorp/vault-client-go/schema"
+)
+
+const (
+	authMethodsSupported     = "token, approle, kubernetes, jwt, cert, userpass"
+	kubernetesTokenPath      = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	tokenRenewalRetryBackoff = 30 * time.Second
+)
+
+// authenticate sets the client token using the configured auth method, and
+// starts a background routine that keeps the token alive for the duration of
+// the scan.
+func (c *clientConfig) authenticate() error {
+	if c.AuthMethod == "" {
+		c.AuthMethod = "token"
+	}
+	if c.AuthMethod != "token" && c.AuthMount == "" {
+		c.AuthMount = c.AuthMethod
+	}
+
+	var ttl time.Duration
+	var renewable bool
+
+	if c.AuthMethod == "token" {
+		token, err := c.lookupToken()
+		if err != nil {
+			return err
+		}
+		if err := c.Client.SetToken(token); err != nil {
+			return fmt.Errorf("error setting token: %w", err)
+		}
+
+		lookup, err := c.Client.Auth.TokenLookUpSelf(c.Ctx, vault.WithNamespace(c.Namespace))
+		if err != nil {
+			log.Printf("unable to look up token, automatic renewal is disabled: %v", err)
+			return nil
+		}
+		if v, ok := lookup.Data["ttl"].(json.Number); ok {
+			seconds, _ := v.Int64()
+			ttl = time.Duration(seconds) * time.Second
+		}
+		renewable, _ = lookup.Data["renewable"].(bool)
+	} else {
+		auth, err := c.login()
+		if err != nil {
+			return err
+		}
+		ttl = time.Duration(auth.LeaseDuration) * time.Second
+		renewable = auth.Renewable
+	}
+
+	go c.keepTokenAlive(ttl, renewable)
+
+	return nil
+}
+
+// lookupToken returns the static token from the -token flag, the VAULT_TOKEN
+// environment variable, or the ~/.vault-token file written by the Vault CLI,
+// in that order of precedence.
+func (c *clientConfig) lookupToken() (string, error) {
+	if c.Token != "" {
+		return c.Token, nil
+	}
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err == nil {
+		token, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+		if err == nil && strings.TrimSpace(string(token)) != "" {
+			return strings.TrimSpace(string(token)), nil
+		}
+	}
+
+	return "", fmt.Errorf("no Vault token provided: set -token, VAULT_TOKEN or ~/.vault-token, or use -authMethod to log in")
+}
+
+// login authenticates against the configured auth method and sets the
+// resulting token on the client.
+func (c *clientConfig) login() (*vault.ResponseAuth, error) {
+	var resp *vault.Response[map[string]interface{}]
+	var err error
+
+	mount := vault.WithMountPath(c.AuthMount)
+	namespace := vault.WithNamespace(c.Namespace)
+
+	switch c.AuthMethod {
+	case "approle":
+		resp, err = c.Client.Auth.AppRoleLogin(c.Ctx, schema.AppRoleLoginRequest{
+			RoleId:   c.RoleID,
+			SecretId: c.SecretID,
+		}, mount, namespace)
+	case "kubernetes":
+		jwtFile := c.JWTFile
+		if jwtFile == "" {
+			jwtFile = kubernetesTokenPath
+		}
+		jwt, readErr := c.readJWT(jwtFile)
+		if readErr != nil {
+			return nil, readErr
+		}
+		resp, err = c.Client.Auth.KubernetesLogin(c.Ctx, schema.KubernetesLoginRequest{
+			Jwt:  jwt,
+			Role: c.AuthRole,
+		}, mount, namespace)
+	case "jwt":
+		jwt, readErr := c.readJWT(c.JWTFile)
+		if readErr != nil {
+			return nil, readErr
+		}
+		resp, err = c.Client.Auth.JwtLogin(c.Ctx, schema.JwtLoginRequest{
+			Jwt:  jwt,
+			Role: c.AuthRole,
+		}, mount, namespace)
+	case "cert":
+		resp, err = c.Client.Auth.CertLogin(c.Ctx, schema.CertLoginRequest{
+			Name: c.AuthRole,
+		}, mount, namespace)
+	case "userpass":
+		resp, err = c.Client.Auth.UserpassLogin(c.Ctx, c.Username, schema.UserpassLoginRequest{
+			Password: c.Password,
+		}, mount, namespace)
+	default:
+		return nil, fmt.Errorf("unsupported auth method %q, supported methods are: %s", c.AuthMethod, authMethodsSupported)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error logging in with auth method %s at auth/%s: %w", c.AuthMethod, c.AuthMount, err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("login with auth method %s at auth/%s returned no token", c.AuthMethod, c.AuthMount)
+	}
+
+	if err := c.Client.SetToken(resp.Auth.ClientToken); err != nil {
+		return nil, fmt.Errorf("error setting token: %w", err)
+	}
+
+	return resp.Auth, nil
+}
+
+// readJWT returns the JWT given with the -jwt flag, or read from jwtFile.
+func (c *clientConfig) readJWT(jwtFile string) (string, error) {
+	if c.JWT != "" {
+		return c.JWT, nil
+	}
+	if jwtFile == "" {
+		return "", fmt.Errorf("auth method %s requires -jwt or -jwtFile", c.AuthMethod)
+	}
+
+	jwt, err := os.ReadFile(jwtFile)
+	if err != nil {
+		return "", fmt.Errorf("error reading JWT from %s: %w", jwtFile, err)
+	}
+
+	return strings.TrimSpace(string(jwt)), nil
+}
+
+// keepTokenAlive renews the client token once two thirds of its TTL have
+// elapsed. Tokens obtained through an auth method are replaced by logging in
+// again once they can no longer be renewed. Tokens without a TTL, such as root
+// tokens, never expire and are left alone.
+func (c *clientConfig) keepTokenAlive(ttl time.Duration, renewable bool) {
+	for ttl > 0 {
+		time.Sleep(ttl * 2 / 3)
+
+		if renewable {
+			resp, err := c.Client.Auth.TokenRenewSelf(c.Ctx, schema.TokenRenewSelfRequest{}, vault.WithNamespace(c.Namespace))
+			if err == nil && resp.Auth != nil {
+				renewed := time.Duration(resp.Auth.LeaseDuration) * time.Second
+				// a shorter TTL than before means the token has reached its
+				// max TTL and will soon expire regardless of renewal
+				if renewed >= ttl || c.AuthMethod == "token" {
+					ttl = renewed
+					continue
+				}
+			} else if err != nil {
+				log.Printf("error renewing token: %v", err)
+			}
+		}
+
+		if c.AuthMethod == "token" {
+			log.Printf("token can no longer be renewed and will expire in less than %s", ttl/3)
+			return
+		}
+
+		auth, err := c.login()
+		for err != nil {
+			log.Printf("error renewing login, retrying in %s: %v", tokenRenewalRetryBackoff, err)
+			time.Sleep(tokenRenewalRetryBackoff)
+			auth, err = c.login()
+		}
+		ttl = time.Duration(auth.LeaseDuration) * time.Second
+		renewable = auth.Renewable
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/czembower/vault-auditor/utils"
+)
+
+// KV v2 API sub-paths through which a secret can be accessed.
+const (
+	kvData     = "data"
+	kvMetadata = "metadata"
+	kvDelete   = "delete"
+	kvUndelete = "undelete"
+	kvDestroy  = "destroy"
+)
+
+// kvOperations lists the KV v2 API sub-paths in a fixed order.
+var kvOperations = []string{kvData, kvMetadata, kvDelete, kvUndelete, kvDestroy}
+
+// policyPathMatches reports whether a request path is matched by a policy
+// path pattern, using Vault's path matching semantics: a `+` segment matches
+// exactly one path segment, and a trailing `*` matches any suffix, including
+// further path segments. All other characters match literally.
+func policyPathMatches(pattern, path string) bool {
+	glob := strings.HasSuffix(pattern, "*")
+	pattern = strings.TrimSuffix(pattern, "*")
+
+	if !strings.Contains(pattern, "+") {
+		if glob {
+			return strings.HasPrefix(path, pattern)
+		}
+		return pattern == path
+	}
+
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+	if len(pathSegments) < len(patternSegments) || (!glob && len(pathSegments) != len(patternSegments)) {
+		return false
+	}
+
+	for idx, segment := range patternSegments {
+		last := idx == len(patternSegments)-1
+		switch {
+		case segment == "+":
+			if pathSegments[idx] == "" {
+				return false
+			}
+			if last && glob && len(pathSegments) > len(patternSegments) {
+				// `+*` still only matches within the final segment
+				return false
+			}
+		case last && glob:
+			return strings.HasPrefix(strings.Join(pathSegments[idx:], "/"), segment)
+		case segment != pathSegments[idx]:
+			return false
+		}
+	}
+
+	return true
+}
+
+// kvLogicalPath converts the API path at which a KV secret was listed into its
+// logical path, i.e. the namespace and mount path followed by the secret key.
+// For KV v2 this removes the metadata/ segment following the mount path.
+func kvLogicalPath(namespace string, engine *secretsEngine, apiPath string) string {
+	mountPath := utils.SetNamespacePath(namespace) + engine.Path
+	if engine.Version != "2" {
+		return apiPath
+	}
+	return mountPath + strings.TrimPrefix(apiPath, mountPath+kvMetadata+"/")
+}
+
+// kvAPIPaths returns the API paths policies must grant for each kind of access
+// to a KV secret, given its logical path. KV v1 secrets are only accessed at
+// their logical path, which is returned under the data key. KV v2 secrets are
+// read and written under data/, while metadata and version management use the
+// metadata/, delete/, undelete/ and destroy/ sub-paths of the mount.
+func kvAPIPaths(namespace string, engine *secretsEngine, secretPath string) map[string]string {
+	if engine.Version != "2" {
+		return map[string]string{kvData: secretPath}
+	}
+
+	mountPath := utils.SetNamespacePath(namespace) + engine.Path
+	key := strings.TrimPrefix(secretPath, mountPath)
+
+	paths := map[string]string{}
+	for _, operation := range kvOperations {
+		paths[operation] = mountPath + operation + "/" + key
+	}
+	return paths
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPolicyPathMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		// exact paths
+		{"secret/data/app", "secret/data/app", true},
+		{"secret/data/app", "secret/data/app2", false},
+		{"secret/data/app", "secret/data", false},
+		{"secret/data/app", "secret/data/app/", false},
+
+		// trailing slash
+		{"secret/data/app/", "secret/data/app/", true},
+		{"secret/data/app/", "secret/data/app", false},
+
+		// `*` matches any suffix, including further segments
+		{"secret/data/*", "secret/data/app", true},
+		{"secret/data/*", "secret/data/app/config", true},
+		{"secret/data/*", "secret/data/", true},
+		{"secret/data/*", "secret/data", false},
+		{"secret/data/app*", "secret/data/app", true},
+		{"secret/data/app*", "secret/data/app-prod/config", true},
+		{"secret/data/app*", "secret/data/ap", false},
+		{"*", "anything/at/all", true},
+
+		// `+` matches exactly one segment
+		{"secret/+/app", "secret/data/app", true},
+		{"secret/+/app", "secret/metadata/app", true},
+		{"secret/+/app", "secret/data/nested/app", false},
+		{"secret/+/app", "secret/app", false},
+		{"+/data/app", "secret/data/app", true},
+		{"secret/data/+", "secret/data/app", true},
+		{"secret/data/+", "secret/data/app/config", false},
+		{"secret/+/+/config", "secret/data/app/config", true},
+		{"secret/+/+/config", "secret/data/app/other", false},
+
+		// `+` never matches an empty segment
+		{"secret/+/app", "secret//app", false},
+		{"secret/data/+", "secret/data/", false},
+		{"+/data/app", "/data/app", false},
+
+		// `+` combined with a trailing `*`
+		{"secret/+/*", "secret/data/app", true},
+		{"secret/+/*", "secret/data/app/config", true},
+		{"secret/+/*", "secret/data/", true},
+		{"secret/+/*", "secret/data", false},
+		{"secret/+/*", "secret//app", false},
+		{"secret/+/app*", "secret/data/app-prod", true},
+		{"secret/+/app*", "secret/data/app/config", true},
+		{"secret/+/app*", "secret/data/other", false},
+
+		// `+*` matches within the final segment only
+		{"secret/data/+*", "secret/data/app", true},
+		{"secret/data/+*", "secret/data/app/config", false},
+		{"secret/data/+*", "secret/data/", false},
+
+		// `+` and `*` elsewhere in a segment are literal
+		{"secret/a+b", "secret/a+b", true},
+		{"secret/a+b", "secret/axb", false},
+		{"secret/a*b", "secret/a*b", true},
+		{"secret/a*b", "secret/axb", false},
+	}
+
+	for _, tt := range tests {
+		if got := policyPathMatches(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("policyPathMatches(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestKVLogicalPath(t *testing.T) {
+	v1 := &secretsEngine{Path: "kv/", Type: "kv", Version: "1"}
+	v2 := &secretsEngine{Path: "kv/", Type: "kv", Version: "2"}
+
+	tests := []struct {
+		namespace string
+		engine    *secretsEngine
+		apiPath   string
+		want      string
+	}{
+		{"root", v1, "kv/app/config", "kv/app/config"},
+		{"team-a", v1, "team-a/kv/app/config", "team-a/kv/app/config"},
+		{"root", v2, "kv/metadata/app/config", "kv/app/config"},
+		{"team-a", v2, "team-a/kv/metadata/app/config", "team-a/kv/app/config"},
+		{"team-a/dev", v2, "team-a/dev/kv/metadata/app", "team-a/dev/kv/app"},
+	}
+
+	for _, tt := range tests {
+		if got := kvLogicalPath(tt.namespace, tt.engine, tt.apiPath); got != tt.want {
+			t.Errorf("kvLogicalPath(%q, v%s, %q) = %q, want %q", tt.namespace, tt.engine.Version, tt.apiPath, got, tt.want)
+		}
+	}
+}
+
+func TestKVAPIPaths(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespace  string
+		engine     *secretsEngine
+		secretPath string
+		want       map[string]string
+	}{
+		{
+			name:       "v1",
+			namespace:  "root",
+			engine:     &secretsEngine{Path: "kv/", Type: "kv", Version: "1"},
+			secretPath: "kv/app/config",
+			want:       map[string]string{kvData: "kv/app/config"},
+		},
+		{
+			name:       "v1 namespaced",
+			namespace:  "team-a",
+			engine:     &secretsEngine{Path: "kv/", Type: "kv", Version: "1"},
+			secretPath: "team-a/kv/app/config",
+			want:       map[string]string{kvData: "team-a/kv/app/config"},
+		},
+		{
+			name:       "v2",
+			namespace:  "root",
+			engine:     &secretsEngine{Path: "secret/", Type: "kv", Version: "2"},
+			secretPath: "secret/app/config",
+			want: map[string]string{
+				kvData:     "secret/data/app/config",
+				kvMetadata: "secret/metadata/app/config",
+				kvDelete:   "secret/delete/app/config",
+				kvUndelete: "secret/undelete/app/config",
+				kvDestroy:  "secret/destroy/app/config",
+			},
+		},
+		{
+			name:       "v2 namespaced",
+			namespace:  "team-a/dev",
+			engine:     &secretsEngine{Path: "kv/", Type: "kv", Version: "2"},
+			secretPath: "team-a/dev/kv/app",
+			want: map[string]string{
+				kvData:     "team-a/dev/kv/data/app",
+				kvMetadata: "team-a/dev/kv/metadata/app",
+				kvDelete:   "team-a/dev/kv/delete/app",
+				kvUndelete: "team-a/dev/kv/undelete/app",
+				kvDestroy:  "team-a/dev/kv/destroy/app",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kvAPIPaths(tt.namespace, tt.engine, tt.secretPath); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kvAPIPaths = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
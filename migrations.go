@@ -0,0 +1,68 @@
+package main
+
+// schemaMigration is one version of the SQL schema. Migrations are applied in
+// order, each at most once, and recorded in the schema_migrations table, so
+// that existing databases are upgraded in place and keep the scans loaded into
+// them. A released migration must never change; changes to the schema are
+// made by appending a new migration.
+type schemaMigration struct {
+	Version     int
+	Description string
+	Create      []*inventoryTable
+}
+
+// scansTable holds one row per scan loaded into the database. Every inventory
+// row refers to the scan it was collected by.
+var scansTable = &inventoryTable{Name: "scans", Key: 1, Columns: []tableColumn{serial("scan_id"), timestamp("started_at"), timestamp("finished_at"), integer("duration_ms"), text("address"), text("base_namespace"), text("version")}}
+
+var schemaMigrations = []schemaMigration{
+	{
+		Version:     1,
+		Description: "scans and scan inventory tables",
+		Create: []*inventoryTable{
+			scansTable,
+			{Name: "namespaces", Key: 1, ScanID: true, Columns: []tableColumn{text("namespace"), text("parent"), integer("policies_only")}},
+			{Name: "usage", ScanID: true, Columns: []tableColumn{text("namespace"), integer("distinct_entities"), integer("clients"), integer("non_entity_clients"), integer("secret_syncs"), integer("acme_clients")}},
+			{Name: "policies", Key: 2, ScanID: true, Columns: []tableColumn{text("namespace"), text("policy")}},
+			{Name: "policy_rules", ScanID: true, Columns: []tableColumn{text("namespace"), text("policy"), text("path"), text("capabilities"), text("allowed_parameters"), text("denied_parameters"), text("required_parameters"), text("min_wrapping_ttl"), text("max_wrapping_ttl")}},
+			{Name: "auth_mounts", Key: 2, ScanID: true, Columns: []tableColumn{text("namespace"), text("mount_path"), text("type")}},
+			{Name: "auth_roles", Key: 4, ScanID: true, Columns: []tableColumn{text("namespace"), text("mount_path"), text("kind"), text("name")}},
+			{Name: "role_policies", ScanID: true, Columns: []tableColumn{text("namespace"), text("mount_path"), text("kind"), text("name"), text("policy")}},
+			{Name: "role_groups", ScanID: true, Columns: []tableColumn{text("namespace"), text("mount_path"), text("kind"), text("name"), text("group_name")}},
+			{Name: "secrets_engines", Key: 2, ScanID: true, Columns: []tableColumn{text("namespace"), text("mount_path"), text("type"), text("version"), integer("item_count")}},
+			{Name: "engine_roles", ScanID: true, Columns: []tableColumn{text("namespace"), text("mount_path"), text("name")}},
+			{Name: "secrets", Key: 3, ScanID: true, Columns: []tableColumn{text("namespace"), text("mount_path"), text("secret_path"), integer("current_version"), timestamp("creation_time"), timestamp("updated_time")}},
+			{Name: "secret_policies", ScanID: true, Columns: []tableColumn{text("namespace"), text("secret_path"), text("policy"), text("access")}},
+			{Name: "secret_capabilities", ScanID: true, Columns: []tableColumn{text("namespace"), text("secret_path"), text("policy"), text("operation"), text("capabilities")}},
+			{Name: "secret_roles", ScanID: true, Columns: []tableColumn{text("namespace"), text("secret_path"), text("role")}},
+			{Name: "entities", Key: 2, ScanID: true, Columns: []tableColumn{text("namespace"), text("entity_id"), text("name")}},
+			{Name: "entity_policies", ScanID: true, Columns: []tableColumn{text("namespace"), text("entity_id"), text("policy"), text("source")}},
+			{Name: "aliases", ScanID: true, Columns: []tableColumn{text("namespace"), text("entity_id"), text("alias_id"), text("name"), text("mount_path"), text("mount_type")}},
+			{Name: "groups", Key: 2, ScanID: true, Columns: []tableColumn{text("namespace"), text("group_id"), text("name"), text("type")}},
+			{Name: "group_policies", ScanID: true, Columns: []tableColumn{text("namespace"), text("group_id"), text("policy")}},
+			{Name: "group_members", ScanID: true, Columns: []tableColumn{text("namespace"), text("group_id"), text("member_type"), text("member_id")}},
+			{Name: "effective_policies", ScanID: true, Columns: []tableColumn{text("namespace"), text("entity_id"), text("policy"), text("source")}},
+			{Name: "effective_paths", ScanID: true, Columns: []tableColumn{text("namespace"), text("entity_id"), text("path"), text("policy"), text("capabilities")}},
+			{Name: "effective_secrets", ScanID: true, Columns: []tableColumn{text("namespace"), text("entity_id"), text("secret_path"), text("operation"), text("capabilities")}},
+			{Name: "unresolved_aliases", ScanID: true, Columns: []tableColumn{text("namespace"), text("entity_id"), text("alias")}},
+			{Name: "errors", ScanID: true, Columns: []tableColumn{text("namespace"), text("error")}},
+		},
+	},
+}
+
+// scanValues returns the scans row for the inventory, leaving out the
+// generated scan_id. Inventories written before scans were recorded have no
+// scan details.
+func (i *vaultInventory) scanValues() []interface{} {
+	if i.Scan == nil {
+		return []interface{}{nil, nil, nil, nil, nil, nil}
+	}
+	return []interface{}{
+		i.Scan.StartTime,
+		i.Scan.EndTime,
+		i.Scan.EndTime.Sub(i.Scan.StartTime).Milliseconds(),
+		nullIfEmpty(i.Scan.Address),
+		nullIfEmpty(i.Scan.BaseNamespace),
+		nullIfEmpty(i.Scan.Version),
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// sampleInventory returns an analyzed inventory with rows in every inventory
+// table.
+func sampleInventory() *vaultInventory {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	i := &vaultInventory{
+		Scan: &scanInfo{Address: "https://vault:8200", BaseNamespace: "root", Version: "test", StartTime: start, EndTime: start.Add(time.Minute)},
+		Namespaces: []namespaceInventory{
+			{
+				Name:         "root",
+				PoliciesOnly: true,
+				Policies: []policy{{Name: "admin", Rules: []policyRule{{
+					Path:               "team-a/kv/*",
+					Capabilities:       []string{"read", "list", "update"},
+					AllowedParameters:  map[string][]interface{}{"version": {}},
+					DeniedParameters:   map[string][]interface{}{"cas": {}},
+					RequiredParameters: []string{"version"},
+					MinWrappingTTL:     "1m",
+					MaxWrappingTTL:     "1h",
+				}}}},
+			},
+			{
+				Name:   "team-a",
+				Parent: "root",
+				Usage:  usageData{DistinctEntities: "2", Clients: "3"},
+				Policies: []policy{{Name: "reader", Rules: []policyRule{
+					{Path: "kv/data/*", Capabilities: []string{"read"}},
+				}}},
+				AuthMounts: []authMount{
+					{Path: "approle/", Type: "approle", Roles: []authRole{{Name: "app", Policies: []string{"reader"}}}},
+					{Path: "ldap/", Type: "ldap", Users: []authRole{{Name: "alice", Policies: []string{"reader"}, Groups: []string{"devs"}}}},
+				},
+				SecretsEngines: []secretsEngine{
+					{Path: "kv/", Type: "kv", Version: "2", Secrets: []staticSecret{{Path: "team-a/kv/app", CurrentVersion: "3", CreationTime: start.Format(time.RFC3339Nano), UpdatedTime: start.Format(time.RFC3339Nano)}}},
+					{Path: "database/", Type: "database", Roles: []string{"app"}},
+				},
+				Entities: []entity{{
+					ID:       "e1",
+					Name:     "alice",
+					Policies: []string{"reader", "missing"},
+					Aliases:  []alias{{ID: "a1", Name: "alice", MountPath: "ldap/", MountType: "ldap"}},
+				}},
+				Groups: []identityGroup{{ID: "g1", Name: "devs", Type: "internal", Policies: []string{"reader"}, MemberEntityIDs: []string{"e1"}}},
+				Errors: []string{"error listing path team-a/database/roles"},
+			},
+		},
+	}
+	i.analyze()
+	i.mapEffectivePermissions()
+
+	return i
+}
+
+func TestMigrationsMatchInventoryTables(t *testing.T) {
+	migrated := map[string]*inventoryTable{}
+	for _, migration := range schemaMigrations {
+		for _, table := range migration.Create {
+			migrated[table.Name] = table
+		}
+	}
+
+	tables := sampleInventory().inventoryTables()
+	if len(migrated) != len(tables)+1 {
+		t.Errorf("migrations create %d tables, want the %d inventory tables and the scans table", len(migrated), len(tables))
+	}
+
+	for _, dialect := range []sqlDialect{postgresDialect{}, sqliteDialect{}, mysqlDialect{}} {
+		for _, table := range tables {
+			if len(table.Rows) == 0 {
+				t.Errorf("sample inventory has no rows in table %s", table.Name)
+			}
+
+			migratedTable, ok := migrated[table.Name]
+			if !ok {
+				t.Errorf("%T: table %s is not created by any migration", dialect, table.Name)
+				continue
+			}
+
+			// inventory tables are loaded with a leading scan_id
+			loaded := *table
+			loaded.ScanID = true
+			if got, want := createTableStatement(dialect, migratedTable, false), createTableStatement(dialect, &loaded, false); got != want {
+				t.Errorf("%T: migrated table %s does not match the inventory table:\n%s\nwant\n%s", dialect, table.Name, got, want)
+			}
+		}
+	}
+}
+
+func TestSQLiteOutput(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "vault-auditor.db")
+	i := sampleInventory()
+
+	// loading twice applies the migrations once and records two scans
+	for range 2 {
+		if err := i.sqliteOutput("sqlite://" + fileName); err != nil {
+			t.Fatalf("sqliteOutput: %v", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", fileName)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+
+	var scans int
+	if err := db.QueryRow("SELECT count(*) FROM " + sqlTableName(sqliteDialect{}, scansTable.Name)).Scan(&scans); err != nil {
+		t.Fatalf("counting scans: %v", err)
+	}
+	if scans != 2 {
+		t.Errorf("scans = %d, want 2", scans)
+	}
+
+	for _, table := range i.inventoryTables() {
+		var rows int
+		if err := db.QueryRow("SELECT count(*) FROM " + sqlTableName(sqliteDialect{}, table.Name) + " WHERE scan_id = 2").Scan(&rows); err != nil {
+			t.Errorf("counting rows of %s: %v", table.Name, err)
+			continue
+		}
+		if rows != len(table.Rows) {
+			t.Errorf("table %s has %d rows, want %d", table.Name, rows, len(table.Rows))
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/czembower/vault-auditor/utils"
@@ -10,20 +11,26 @@ import (
 
 type namespaceInventory struct {
 	Name           string          `json:"name,omitempty"`
+	Parent         string          `json:"parent,omitempty"`
 	AuthMounts     []authMount     `json:"authMounts,omitempty"`
 	SecretsEngines []secretsEngine `json:"secretsEngines,omitempty"`
 	Entities       []entity        `json:"entities,omitempty"`
+	Groups         []identityGroup `json:"groups,omitempty"`
 	Policies       []policy        `json:"policies,omitempty"`
 	Errors         []string        `json:"errors,omitempty"`
 	Usage          usageData       `json:"usage,omitempty"`
+	// PoliciesOnly is set on namespaces excluded by the namespace filters
+	// that are ancestors of included namespaces. Only their policies are
+	// collected, as they may grant access to secrets in their descendants.
+	PoliciesOnly bool `json:"policiesOnly,omitempty"`
 }
 
 type authMount struct {
 	Path   string     `json:"path,omitempty"`
 	Type   string     `json:"type,omitempty"`
 	Roles  []authRole `json:"authRoles,omitempty"`
-	Users  []string   `json:"users,omitempty"`
-	Groups []string   `json:"groups,omitempty"`
+	Users  []authRole `json:"users,omitempty"`
+	Groups []authRole `json:"groups,omitempty"`
 	Certs  []authRole `json:"certs,omitempty"`
 }
 
@@ -44,6 +51,7 @@ func (i *vaultInventory) getMounts(c *clientConfig, namespace string) {
 	}
 	namespaceInventory := namespacePool.Get().(*namespaceInventory)
 	namespaceInventory.Name = namespace
+	namespaceInventory.Parent = parentNamespace(namespace)
 
 	authMountsResponse, err := c.Client.Read(c.Ctx, "sys/auth", vault.WithNamespace(namespace))
 	if err != nil {
@@ -54,6 +62,9 @@ func (i *vaultInventory) getMounts(c *clientConfig, namespace string) {
 			var authMount authMount
 			authMount.Path = x
 			authMount.Type = config.(map[string]interface{})["type"].(string)
+			if !c.includeAuthMount(namespace, authMount.Path, authMount.Type) {
+				continue
+			}
 			namespaceInventory.AuthMounts = append(namespaceInventory.AuthMounts, authMount)
 		}
 	}
@@ -67,6 +78,9 @@ func (i *vaultInventory) getMounts(c *clientConfig, namespace string) {
 			var secretsEngine secretsEngine
 			secretsEngine.Path = x
 			secretsEngine.Type = config.(map[string]interface{})["type"].(string)
+			if !c.includeSecretsEngine(namespace, secretsEngine.Path, secretsEngine.Type) {
+				continue
+			}
 			if v, ok := config.(map[string]interface{})["options"]; ok {
 				if v != nil {
 					if version, ok := v.(map[string]interface{})["version"]; ok {
@@ -78,5 +92,19 @@ func (i *vaultInventory) getMounts(c *clientConfig, namespace string) {
 		}
 	}
 
+	i.mu.Lock()
 	i.Namespaces = append(i.Namespaces, *namespaceInventory)
+	i.mu.Unlock()
+}
+
+// parentNamespace returns the full path of the namespace containing the given
+// namespace, or an empty string for the root namespace.
+func parentNamespace(namespace string) string {
+	if namespace == "root" {
+		return ""
+	}
+	if idx := strings.LastIndex(namespace, "/"); idx >= 0 {
+		return namespace[:idx]
+	}
+	return "root"
 }
@@ -4,12 +4,15 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
+const csvTablesDir = "inventory-csv"
+
 func createFile(outputFormat string) (*os.File, error) {
 	var fileName string
 
@@ -18,68 +21,193 @@ func createFile(outputFormat string) (*os.File, error) {
 		fileName = "inventory.json"
 	case "csv":
 		fileName = "secrets.csv"
+	case "ndjson":
+		fileName = "inventory.ndjson"
 	default:
 		return nil, fmt.Errorf("unsupported output format: %s", outputFormat)
 	}
 
 	file, err := os.Create(fileName)
 	if err != nil {
-		fmt.Println("Error creating file:", err)
-		return nil, err
+		return nil, fmt.Errorf("error creating file: %w", err)
 	}
 
 	return file, nil
 }
 
-func (i *vaultInventory) toCSV() {
+// toCSV writes the static secrets and the policies granting access to them
+// to ./secrets.csv, and the full inventory, one file per table, to the
+// ./inventory-csv directory.
+func (i *vaultInventory) toCSV() error {
+	if err := i.toCSVTables(csvTablesDir); err != nil {
+		return err
+	}
+
 	file, err := createFile("csv")
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
-	defer file.Close()
 
 	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	writer.Write([]string{"Namespace", "Engine Type", "Engine Version", "Engine Path", "Secret Path", "Current Version", "Creation Time", "Updated Time", "Access-Granting Policies", "Namespace Roles with Access-Granting Policies"})
+	writer.Write([]string{"Namespace", "Engine Type", "Engine Version", "Engine Path", "Secret Path", "Current Version", "Creation Time", "Updated Time", "Access-Granting Policies", "Read Policies", "Write Policies", "Delete Policies", "Metadata Policies", "Undelete Policies", "Destroy Policies", "Namespace Roles with Access-Granting Policies"})
 
 	for _, namespace := range i.Namespaces {
 		for _, engine := range namespace.SecretsEngines {
 			for _, secret := range engine.Secrets {
-				writer.Write([]string{namespace.Name, engine.Type, engine.Version, engine.Path, secret.Path, string(secret.CurrentVersion), secret.CreationTime, secret.UpdatedTime, strings.Join(secret.Policies, ","), strings.Join(secret.Roles, ",")})
+				writer.Write([]string{namespace.Name, engine.Type, engine.Version, engine.Path, secret.Path, string(secret.CurrentVersion), secret.CreationTime, secret.UpdatedTime, strings.Join(secret.Policies, ","), strings.Join(secret.ReadPolicies, ","), strings.Join(secret.WritePolicies, ","), strings.Join(secret.DeletePolicies, ","), strings.Join(secret.MetadataPolicies, ","), strings.Join(secret.UndeletePolicies, ","), strings.Join(secret.DestroyPolicies, ","), strings.Join(secret.Roles, ",")})
 			}
 		}
 	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing %s: %w", file.Name(), err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %w", file.Name(), err)
+	}
+
+	return nil
 }
 
-func (i *vaultInventory) toJSON(stdout bool) {
-	jsonBytes, _ := json.MarshalIndent(i, "", "  ")
+// toCSVTables writes each table of the inventory to a CSV file of the same
+// name in dir.
+func (i *vaultInventory) toCSVTables(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating directory %s: %w", dir, err)
+	}
 
-	if !stdout {
-		file, err := createFile("json")
+	for _, table := range i.inventoryTables() {
+		file, err := os.Create(filepath.Join(dir, table.Name+".csv"))
 		if err != nil {
-			log.Fatalln(err)
+			return fmt.Errorf("error creating file: %w", err)
+		}
+
+		writer := csv.NewWriter(file)
+		writer.Write(table.columnNames())
+		for _, row := range table.Rows {
+			record := make([]string, len(row))
+			for idx, value := range row {
+				record[idx] = csvValue(value)
+			}
+			writer.Write(record)
 		}
-		defer file.Close()
-		file.Write(jsonBytes)
-	} else {
-		fmt.Println(string(jsonBytes))
+		writer.Flush()
+
+		if err := writer.Error(); err != nil {
+			file.Close()
+			return fmt.Errorf("error writing %s: %w", file.Name(), err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("error writing %s: %w", file.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
 	}
 }
 
-func (i *vaultInventory) toSQL(sqlConnectionString string) {
-	// Connect to SQL database and insert data
+// toJSON writes the inventory to ./inventory.json, or to stdout.
+func (i *vaultInventory) toJSON(stdout bool) error {
+	jsonBytes, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+
+	if stdout {
+		if _, err := fmt.Println(string(jsonBytes)); err != nil {
+			return fmt.Errorf("error writing to stdout: %w", err)
+		}
+		return nil
+	}
+
+	file, err := createFile("json")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(jsonBytes); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing %s: %w", file.Name(), err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %w", file.Name(), err)
+	}
+
+	return nil
+}
+
+// toNDJSON writes one record per inventory object to ./inventory.ndjson.
+// Records of each namespace are grouped together, followed by the cluster
+// usage data and errors.
+func (i *vaultInventory) toNDJSON() error {
+	records, err := newRecordWriter(false)
+	if err != nil {
+		return err
+	}
+
+	for idx := range i.Namespaces {
+		ns := &i.Namespaces[idx]
+		records.namespace(ns)
+		for _, p := range ns.Policies {
+			records.policy(ns, p)
+		}
+		for _, am := range ns.AuthMounts {
+			records.authMount(ns, am)
+		}
+		for seIdx := range ns.SecretsEngines {
+			engine := &ns.SecretsEngines[seIdx]
+			records.secretsEngine(ns, engine)
+			for _, secret := range engine.Secrets {
+				records.secret(ns, engine, secret)
+			}
+		}
+		for _, e := range ns.Entities {
+			records.entity(ns, e)
+		}
+		for _, g := range ns.Groups {
+			records.group(ns, g)
+		}
+		records.errors(ns.Name, ns.Errors)
+	}
+	records.usage(i.Usage)
+	records.errors("", i.Errors)
+
+	return records.close()
+}
+
+// toSQL loads the inventory into the database given by the connection
+// string, choosing the backend from its scheme.
+func (i *vaultInventory) toSQL(sqlConnectionString string) error {
 	url, err := url.Parse(sqlConnectionString)
 	if err != nil {
-		log.Fatalf("url.Parse: %v", err)
+		return fmt.Errorf("url.Parse: %w", err)
 	}
 
 	switch url.Scheme {
 	case "postgres":
-		i.postgresOutput(sqlConnectionString)
+		err = i.postgresOutput(sqlConnectionString)
+	case "sqlite":
+		err = i.sqliteOutput(sqlConnectionString)
+	case "mysql":
+		err = i.mysqlOutput(sqlConnectionString)
 	default:
-		log.Fatalf("Unsupported SQL driver: %s", url.Scheme)
+		return fmt.Errorf("unsupported SQL driver: %s", url.Scheme)
+	}
+	if err != nil {
+		return err
 	}
 
 	fmt.Println("Data inserted successfully.")
+	return nil
 }
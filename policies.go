@@ -5,11 +5,43 @@ import (
 	"strings"
 
 	"github.com/czembower/vault-auditor/utils"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
 )
 
 type policy struct {
-	Name  string   `json:"name,omitempty"`
-	Paths []string `json:"paths,omitempty"`
+	Name  string       `json:"name,omitempty"`
+	Rules []policyRule `json:"rules,omitempty"`
+}
+
+type policyRule struct {
+	Path               string                   `json:"path,omitempty"`
+	Capabilities       []string                 `json:"capabilities,omitempty"`
+	AllowedParameters  map[string][]interface{} `json:"allowedParameters,omitempty"`
+	DeniedParameters   map[string][]interface{} `json:"deniedParameters,omitempty"`
+	RequiredParameters []string                 `json:"requiredParameters,omitempty"`
+	MinWrappingTTL     string                   `json:"minWrappingTTL,omitempty"`
+	MaxWrappingTTL     string                   `json:"maxWrappingTTL,omitempty"`
+}
+
+// hclPathRule mirrors the body of a `path` stanza as accepted by Vault.
+type hclPathRule struct {
+	Policy             string                   `hcl:"policy"`
+	Capabilities       []string                 `hcl:"capabilities"`
+	AllowedParameters  map[string][]interface{} `hcl:"allowed_parameters"`
+	DeniedParameters   map[string][]interface{} `hcl:"denied_parameters"`
+	RequiredParameters []string                 `hcl:"required_parameters"`
+	MinWrappingTTL     interface{}              `hcl:"min_wrapping_ttl"`
+	MaxWrappingTTL     interface{}              `hcl:"max_wrapping_ttl"`
+}
+
+// legacyPolicyCapabilities expands the pre-0.5 `policy = "..."` shorthand into
+// the equivalent list of capabilities.
+var legacyPolicyCapabilities = map[string][]string{
+	"deny":  {"deny"},
+	"read":  {"read", "list"},
+	"write": {"create", "read", "update", "delete", "list"},
+	"sudo":  {"create", "read", "update", "delete", "list", "sudo"},
 }
 
 func (ns *namespaceInventory) scanPolicies(c *clientConfig) {
@@ -49,7 +81,10 @@ func (ns *namespaceInventory) processPolicy(c *clientConfig, basePath, policyNam
 	}
 
 	if rules, ok := policyDetails.Data["rules"].(string); ok {
-		p.Paths = extractPathsFromRules(rules)
+		p.Rules, err = parsePolicyRules(rules)
+		if err != nil {
+			utils.AppendError(fmt.Sprintf("error parsing rules for policy %s at path %s: %v", policyName, policyPath, err), &ns.Errors)
+		}
 	} else {
 		utils.AppendError(fmt.Sprintf("invalid or missing rules for policy %s at path %s", policyName, policyPath), &ns.Errors)
 	}
@@ -57,17 +92,60 @@ func (ns *namespaceInventory) processPolicy(c *clientConfig, basePath, policyNam
 	ns.Policies = append(ns.Policies, p)
 }
 
-func extractPathsFromRules(rules string) []string {
-	var paths []string
-	rulesList := strings.Split(rules, "\n")
+// parsePolicyRules parses HCL or JSON formatted policy rules into a list of
+// path rules, one per `path` stanza.
+func parsePolicyRules(rules string) ([]policyRule, error) {
+	root, err := hcl.Parse(rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	list, ok := root.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse policy: does not contain a root object")
+	}
 
-	for _, rule := range rulesList {
-		if strings.HasPrefix(rule, "path") {
-			pathParts := strings.SplitN(rule, "\"", 3)
-			if len(pathParts) >= 2 {
-				paths = append(paths, pathParts[1])
+	var pathRules []policyRule
+	for _, item := range list.Filter("path").Items {
+		if len(item.Keys) == 0 {
+			return pathRules, fmt.Errorf("path stanza is missing a path")
+		}
+		key, ok := item.Keys[0].Token.Value().(string)
+		if !ok {
+			return pathRules, fmt.Errorf("invalid path key at line %d", item.Pos().Line)
+		}
+
+		var raw hclPathRule
+		if err := hcl.DecodeObject(&raw, item.Val); err != nil {
+			return pathRules, fmt.Errorf("path %q: %w", key, err)
+		}
+
+		rule := policyRule{
+			Path:               key,
+			Capabilities:       raw.Capabilities,
+			AllowedParameters:  raw.AllowedParameters,
+			DeniedParameters:   raw.DeniedParameters,
+			RequiredParameters: raw.RequiredParameters,
+		}
+		if raw.Policy != "" {
+			capabilities, ok := legacyPolicyCapabilities[strings.ToLower(raw.Policy)]
+			if !ok {
+				return pathRules, fmt.Errorf("path %q: invalid policy %q", key, raw.Policy)
 			}
+			rule.Capabilities = append(rule.Capabilities, capabilities...)
 		}
+		if utils.StringInSlice("deny", rule.Capabilities) {
+			rule.Capabilities = []string{"deny"}
+		}
+		if raw.MinWrappingTTL != nil {
+			rule.MinWrappingTTL = fmt.Sprint(raw.MinWrappingTTL)
+		}
+		if raw.MaxWrappingTTL != nil {
+			rule.MaxWrappingTTL = fmt.Sprint(raw.MaxWrappingTTL)
+		}
+
+		pathRules = append(pathRules, rule)
 	}
-	return paths
+
+	return pathRules, nil
 }
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl"
+)
+
+func TestParsePolicyRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		want  []policyRule
+	}{
+		{
+			name: "hcl",
+			rules: `
+# read access to application secrets
+path "secret/data/app/*" {
+  capabilities = ["read", "list"]
+}
+
+path "secret/metadata/+/config" {
+  capabilities = ["read"]
+}
+
+path "auth/token/create" {
+  capabilities       = ["create", "update"]
+  min_wrapping_ttl   = "1m"
+  max_wrapping_ttl   = "1h"
+  required_parameters = ["role"]
+  allowed_parameters = {
+    "role" = ["app", "ci"]
+    "ttl"  = []
+  }
+  denied_parameters = {
+    "policies" = []
+  }
+}
+`,
+			want: []policyRule{
+				{Path: "secret/data/app/*", Capabilities: []string{"read", "list"}},
+				{Path: "secret/metadata/+/config", Capabilities: []string{"read"}},
+				{
+					Path:               "auth/token/create",
+					Capabilities:       []string{"create", "update"},
+					AllowedParameters:  map[string][]interface{}{"role": {"app", "ci"}, "ttl": {}},
+					DeniedParameters:   map[string][]interface{}{"policies": {}},
+					RequiredParameters: []string{"role"},
+					MinWrappingTTL:     "1m",
+					MaxWrappingTTL:     "1h",
+				},
+			},
+		},
+		{
+			name: "hcl without comments or blank lines",
+			rules: `path "sys/mounts" {
+  capabilities = ["read"]
+}
+path "sys/policies/acl/*" {
+  capabilities = ["create", "read", "update", "delete", "list", "sudo"]
+}`,
+			want: []policyRule{
+				{Path: "sys/mounts", Capabilities: []string{"read"}},
+				{Path: "sys/policies/acl/*", Capabilities: []string{"create", "read", "update", "delete", "list", "sudo"}},
+			},
+		},
+		{
+			name: "json",
+			rules: `{
+  "path": {
+    "secret/data/app/*": {
+      "capabilities": ["read", "list"]
+    },
+    "transit/encrypt/app": {
+      "capabilities": ["update"],
+      "allowed_parameters": {"plaintext": []}
+    }
+  }
+}`,
+			want: []policyRule{
+				{Path: "secret/data/app/*", Capabilities: []string{"read", "list"}},
+				{Path: "transit/encrypt/app", Capabilities: []string{"update"}, AllowedParameters: map[string][]interface{}{"plaintext": {}}},
+			},
+		},
+		{
+			name: "legacy policy",
+			rules: `
+path "secret/read/*" { policy = "read" }
+path "secret/write/*" { policy = "write" }
+path "sys/*" { policy = "sudo" }
+path "secret/blocked/*" { policy = "deny" }
+`,
+			want: []policyRule{
+				{Path: "secret/read/*", Capabilities: []string{"read", "list"}},
+				{Path: "secret/write/*", Capabilities: []string{"create", "read", "update", "delete", "list"}},
+				{Path: "sys/*", Capabilities: []string{"create", "read", "update", "delete", "list", "sudo"}},
+				{Path: "secret/blocked/*", Capabilities: []string{"deny"}},
+			},
+		},
+		{
+			name: "deny replaces other capabilities",
+			rules: `path "secret/*" {
+  capabilities = ["read", "deny"]
+}`,
+			want: []policyRule{{Path: "secret/*", Capabilities: []string{"deny"}}},
+		},
+		{
+			name:  "empty policy",
+			rules: ``,
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePolicyRules(tt.rules)
+			if err != nil {
+				t.Fatalf("parsePolicyRules: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePolicyRules =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePolicyRulesHeredoc(t *testing.T) {
+	// policies written with a heredoc, as in Terraform or the vault CLI, are
+	// stored by Vault as the text between the markers
+	rules := "path \"secret/data/app\" {\n  capabilities = [\"read\"]\n}\n"
+	heredoc := "<<EOT\n" + rules + "EOT\n"
+
+	var wrapped struct {
+		Policy string `hcl:"policy"`
+	}
+	if err := hcl.Decode(&wrapped, "policy = "+heredoc); err != nil {
+		t.Fatalf("decoding heredoc: %v", err)
+	}
+
+	got, err := parsePolicyRules(wrapped.Policy)
+	if err != nil {
+		t.Fatalf("parsePolicyRules: %v", err)
+	}
+	want := []policyRule{{Path: "secret/data/app", Capabilities: []string{"read"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePolicyRules = %#v, want %#v", got, want)
+	}
+}
+
+func TestParsePolicyRulesMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+	}{
+		{"unterminated stanza", `path "secret/*" { capabilities = ["read"]`},
+		{"invalid legacy policy", `path "secret/*" { policy = "admin" }`},
+		{"capabilities not a list", `path "secret/*" { capabilities = { read = true } }`},
+		{"invalid json", `{"path": {"secret/*" {"capabilities": ["read"]}}}`},
+		{"json capabilities not a list", `{"path": {"secret/*": {"capabilities": "read"}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parsePolicyRules(tt.rules); err == nil {
+				t.Errorf("parsePolicyRules(%q) succeeded, want an error", tt.rules)
+			}
+		})
+	}
+}
@@ -1,84 +1,79 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"strings"
+	"strconv"
 
 	"github.com/lib/pq"
 )
 
-func (i *vaultInventory) postgresOutput(sqlConnectionString string) error {
-	db, err := sql.Open("postgres", sqlConnectionString)
-	if err != nil {
-		return fmt.Errorf("sql.Open: %w", err)
-	}
-	defer db.Close()
+// postgresMigrationLock is the key of the advisory lock held while schema
+// migrations are applied.
+const postgresMigrationLock = 0x7661756c74
 
-	if err = db.Ping(); err != nil {
-		return fmt.Errorf("db.Ping: %w", err)
-	}
-	fmt.Println("Connected to database.")
+type postgresDialect struct{}
 
-	dropTableSQL := `DROP TABLE IF EXISTS secrets;`
-	_, err = db.Exec(dropTableSQL)
-	if err != nil {
-		return fmt.Errorf("Error dropping table: %w", err)
-	}
+var postgresColumnTypes = map[columnType]string{
+	textColumn:    "TEXT",
+	integerColumn: "BIGINT",
+	timeColumn:    "TIMESTAMPTZ",
+	serialColumn:  "BIGSERIAL",
+}
 
-	createTableSQL := `
-	CREATE TABLE secrets (
-		secret_path VARCHAR(255) PRIMARY KEY,
-		namespace VARCHAR(100),
-		engine_type VARCHAR(10),
-		engine_version CHAR(1),
-		engine_path VARCHAR(255),
-		current_version VARCHAR(255),
-		creation_time TIMESTAMP,
-		updated_time TIMESTAMP,
-		access_granting_policies TEXT,
-		namespace_roles_with_access_granting_policies TEXT
-	);`
+// postgresOutput loads the inventory into a PostgreSQL database.
+func (i *vaultInventory) postgresOutput(sqlConnectionString string) error {
+	return i.sqlOutput("postgres", sqlConnectionString, postgresDialect{})
+}
 
-	_, err = db.Exec(createTableSQL)
-	if err != nil {
-		return fmt.Errorf("Error creating table: %w", err)
-	}
+func (postgresDialect) columnType(column tableColumn) string {
+	return postgresColumnTypes[column.Type]
+}
 
-	txn, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("%w", err)
-	}
+func (d postgresDialect) primaryKey(columns []tableColumn) []string {
+	return []string{primaryKeyConstraint(d, columns)}
+}
 
-	stmt, err := txn.Prepare(pq.CopyIn("secrets", "secret_path", "namespace", "engine_type", "engine_version", "engine_path", "current_version", "creation_time", "updated_time", "access_granting_policies", "namespace_roles_with_access_granting_policies"))
-	if err != nil {
-		return fmt.Errorf("%w", err)
-	}
+func (postgresDialect) quote(identifier string) string {
+	return pq.QuoteIdentifier(identifier)
+}
 
-	for _, namespace := range i.Namespaces {
-		for _, engine := range namespace.SecretsEngines {
-			for _, secret := range engine.Secrets {
-				stmt.Exec(secret.Path, namespace.Name, engine.Type, engine.Version, engine.Path, string(secret.CurrentVersion), secret.CreationTime, secret.UpdatedTime, strings.Join(secret.Policies, ","), strings.Join(secret.Roles, ","))
-			}
-		}
-	}
+func (postgresDialect) placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
 
-	exec, err := stmt.Exec()
-	if err != nil {
-		lastInsert, _ := exec.LastInsertId()
-		rowsAffected, _ := exec.RowsAffected()
-		return fmt.Errorf("exec: %w: lastInsert: %b rowsAffected: %b", err, lastInsert, rowsAffected)
+func (postgresDialect) lockMigrations(ctx context.Context, conn *sql.Conn) (func(), error) {
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, postgresMigrationLock); err != nil {
+		return nil, err
 	}
+	return func() {
+		conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, postgresMigrationLock)
+	}, nil
+}
+
+func (postgresDialect) insertScan(txn *sql.Tx, query string, args ...interface{}) (int64, error) {
+	var scanID int64
+	err := txn.QueryRow(query+` RETURNING scan_id`, args...).Scan(&scanID)
+	return scanID, err
+}
 
-	err = stmt.Close()
+// load bulk loads the rows of the table with COPY.
+func (postgresDialect) load(txn *sql.Tx, scanID int64, table *inventoryTable) error {
+	stmt, err := txn.Prepare(pq.CopyIn(sqlTablePrefix+table.Name, append([]string{"scan_id"}, table.columnNames()...)...))
 	if err != nil {
-		return fmt.Errorf("close: %w", err)
+		return fmt.Errorf("error preparing copy into %s: %w", table.Name, err)
 	}
+	defer stmt.Close()
 
-	err = txn.Commit()
-	if err != nil {
-		return fmt.Errorf("commit: %w", err)
+	for _, row := range table.Rows {
+		if _, err := stmt.Exec(append([]interface{}{scanID}, row...)...); err != nil {
+			return fmt.Errorf("error copying into %s: %w", table.Name, err)
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		return fmt.Errorf("error copying into %s: %w", table.Name, err)
 	}
 
-	return nil
+	return stmt.Close()
 }
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/czembower/vault-auditor/utils"
+)
+
+const queryHelpMessage = `
+The query subcommand performs a reverse lookup against a previously written
+inventory file, listing every policy, auth method role, cert, user and group,
+identity entity and identity group that can reach the given path, along with
+the capabilities granted. The path is relative to the given namespace and may
+be any API path, e.g. kv/data/app/config for a KV v2 secret. No requests are
+made to Vault.`
+
+type queryResult struct {
+	Namespace string           `json:"namespace,omitempty"`
+	Path      string           `json:"path,omitempty"`
+	Policies  []pathGrant      `json:"policies,omitempty"`
+	AuthRoles []authRoleGrant  `json:"authRoles,omitempty"`
+	Entities  []principalGrant `json:"entities,omitempty"`
+	Groups    []principalGrant `json:"groups,omitempty"`
+}
+
+// authRoleGrant is an auth method role, cert, user or group whose policies
+// reach the queried path.
+type authRoleGrant struct {
+	Namespace    string   `json:"namespace,omitempty"`
+	Mount        string   `json:"mount,omitempty"`
+	Type         string   `json:"type,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	Policies     []string `json:"policies,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// principalGrant is an identity entity or group whose policies reach the
+// queried path.
+type principalGrant struct {
+	Namespace    string   `json:"namespace,omitempty"`
+	ID           string   `json:"id,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	Policies     []string `json:"policies,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+func runQuery(args []string) error {
+	var inventoryFile string
+	var namespace string
+	var path string
+
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	fs.StringVar(&inventoryFile, "inventory", "inventory.json", "Inventory file written by a previous scan")
+	fs.StringVar(&namespace, "namespace", "root", "Namespace of the queried path")
+	fs.StringVar(&path, "path", "", "API path to query, relative to the namespace")
+	fs.Usage = func() {
+		fmt.Println(queryHelpMessage)
+		fmt.Fprintf(fs.Output(), "\nUsage of vault-auditor query:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if strings.Trim(path, "/") == "" {
+		return fmt.Errorf("missing required flag: path")
+	}
+
+	i, err := loadInventory(inventoryFile)
+	if err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.MarshalIndent(i.query(namespace, path), "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+	if _, err := fmt.Println(string(jsonBytes)); err != nil {
+		return fmt.Errorf("error writing to stdout: %w", err)
+	}
+
+	return nil
+}
+
+// query resolves which policies, auth roles, entities and groups grant access
+// to a path. Only the policies and auth roles of the namespace of the path and
+// its ancestors are considered, as policies cannot grant access to paths
+// outside of their own namespace tree. Entities and groups are considered in
+// every namespace, along with the policies they inherit from parent groups.
+// Leading and trailing slashes of the namespace and leading slashes of the
+// path are ignored, so that team-a/ and /kv/data/app are accepted.
+func (i *vaultInventory) query(namespace, path string) queryResult {
+	namespace = strings.Trim(namespace, "/")
+	if namespace == "" {
+		namespace = "root"
+	}
+	path = strings.TrimLeft(path, "/")
+
+	result := queryResult{
+		Namespace: namespace,
+		Path:      utils.SetNamespacePath(namespace) + path,
+	}
+
+	// grants returns the capabilities a set of policies grants on the path, or
+	// nil if it grants none
+	grants := func(ns string, policies []string) []string {
+		capabilities := resolveCapabilities(i.policySetRules(ns, policies), result.Path)
+		if len(capabilities) == 0 || utils.StringInSlice("deny", capabilities) {
+			return nil
+		}
+		return capabilities
+	}
+
+	for nsIdx := range i.Namespaces {
+		ns := &i.Namespaces[nsIdx]
+		if ns.Name != namespace && !isAncestorNamespace(ns.Name, namespace) {
+			continue
+		}
+
+		for _, p := range ns.Policies {
+			if capabilities := resolveCapabilities(policyRules(ns.Name, p), result.Path); capabilities != nil {
+				name := p.Name
+				if ns.Name != namespace {
+					name = name + " (" + ns.Name + ")"
+				}
+				result.Policies = append(result.Policies, pathGrant{Policy: name, Capabilities: capabilities})
+			}
+		}
+
+		for _, am := range ns.AuthMounts {
+			for _, kind := range []struct {
+				name  string
+				items []authRole
+			}{{"role", am.Roles}, {"cert", am.Certs}, {"user", am.Users}, {"group", am.Groups}} {
+				for _, item := range kind.items {
+					policies := item.Policies
+					if kind.name == "user" {
+						policies = am.userPolicies(item)
+					}
+					if capabilities := grants(ns.Name, policies); capabilities != nil {
+						result.AuthRoles = append(result.AuthRoles, authRoleGrant{
+							Namespace:    ns.Name,
+							Mount:        am.Path,
+							Type:         kind.name,
+							Name:         item.Name,
+							Policies:     policies,
+							Capabilities: capabilities,
+						})
+					}
+				}
+			}
+		}
+	}
+
+	// entities and groups in other namespaces may be members of groups in the
+	// namespace tree of the path, and inherit their policies
+	groups := i.groupIndex()
+	for nsIdx := range i.Namespaces {
+		ns := &i.Namespaces[nsIdx]
+
+		for _, e := range ns.Entities {
+			var policies []string
+			sources, _ := i.entityPolicySources(ns, e, groups)
+			for _, source := range sources {
+				if !utils.StringInSlice(source.Policy, policies) {
+					policies = append(policies, source.Policy)
+				}
+			}
+			if capabilities := grants(ns.Name, policies); capabilities != nil {
+				result.Entities = append(result.Entities, principalGrant{Namespace: ns.Name, ID: e.ID, Name: e.Name, Policies: policies, Capabilities: capabilities})
+			}
+		}
+
+		for _, g := range ns.Groups {
+			memberOf := map[string]bool{g.ID: true}
+			groups.expandGroupMembership(memberOf)
+			policies := groups.groupPolicies(memberOf, ns.Name)
+			if capabilities := grants(ns.Name, policies); capabilities != nil {
+				result.Groups = append(result.Groups, principalGrant{Namespace: ns.Name, ID: g.ID, Name: g.Name, Policies: policies, Capabilities: capabilities})
+			}
+		}
+	}
+
+	return result
+}
+
+// loadInventory reads an inventory previously written in JSON format.
+func loadInventory(fileName string) (*vaultInventory, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("error reading inventory file: %w", err)
+	}
+
+	var i vaultInventory
+	if err := json.Unmarshal(data, &i); err != nil {
+		return nil, fmt.Errorf("error parsing inventory file %s: %w", fileName, err)
+	}
+
+	return &i, nil
+}
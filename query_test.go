@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	i := &vaultInventory{Namespaces: []namespaceInventory{
+		{
+			Name:     "root",
+			Policies: []policy{{Name: "admin", Rules: []policyRule{{Path: "team-a/kv/*", Capabilities: []string{"read", "update"}}}}},
+		},
+		{
+			Name:     "team-a",
+			Parent:   "root",
+			Policies: []policy{{Name: "reader", Rules: []policyRule{{Path: "kv/data/app", Capabilities: []string{"read"}}}}},
+			AuthMounts: []authMount{{
+				Path:  "approle/",
+				Type:  "approle",
+				Roles: []authRole{{Name: "app", Policies: []string{"reader"}}},
+			}},
+			Entities: []entity{{ID: "e1", Name: "alice", Policies: []string{"reader"}}},
+		},
+	}}
+
+	want := queryResult{
+		Namespace: "team-a",
+		Path:      "team-a/kv/data/app",
+		Policies: []pathGrant{
+			{Policy: "admin (root)", Capabilities: []string{"read", "update"}},
+			{Policy: "reader", Capabilities: []string{"read"}},
+		},
+		AuthRoles: []authRoleGrant{{Namespace: "team-a", Mount: "approle/", Type: "role", Name: "app", Policies: []string{"reader"}, Capabilities: []string{"read"}}},
+		Entities:  []principalGrant{{Namespace: "team-a", ID: "e1", Name: "alice", Policies: []string{"reader"}, Capabilities: []string{"read"}}},
+	}
+
+	for _, tt := range []struct{ namespace, path string }{
+		{"team-a", "kv/data/app"},
+		{"team-a/", "kv/data/app"},
+		{"/team-a/", "/kv/data/app"},
+	} {
+		if got := i.query(tt.namespace, tt.path); !reflect.DeepEqual(got, want) {
+			t.Errorf("query(%q, %q) =\n%+v\nwant\n%+v", tt.namespace, tt.path, got, want)
+		}
+	}
+
+	for _, namespace := range []string{"root", "/", ""} {
+		got := i.query(namespace, "/team-a/kv/data/app")
+		if got.Namespace != "root" || got.Path != "team-a/kv/data/app" {
+			t.Errorf("query(%q, /team-a/kv/data/app) queried %s in %s, want team-a/kv/data/app in root", namespace, got.Path, got.Namespace)
+		}
+		if wantPolicies := []pathGrant{{Policy: "admin", Capabilities: []string{"read", "update"}}}; !reflect.DeepEqual(got.Policies, wantPolicies) {
+			t.Errorf("query(%q, /team-a/kv/data/app) policies = %+v, want %+v", namespace, got.Policies, wantPolicies)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// recordWriter writes newline-delimited JSON records, one per inventory
+// object. Each record is the JSON encoding of the object, tagged with its
+// record type and namespace. It is safe for concurrent use; the first write
+// error is kept and returned by close.
+type recordWriter struct {
+	out    *bufio.Writer
+	closer io.Closer
+	err    error
+	mu     sync.Mutex
+}
+
+// recordHeader holds the tags added to each record. The record type is kept
+// under its own key, as several inventory objects have a type field of their
+// own.
+type recordHeader struct {
+	Type      string `json:"recordType"`
+	Namespace string `json:"namespace,omitempty"`
+	Mount     string `json:"mount,omitempty"`
+}
+
+// newRecordWriter writes records to stdout, or to the file created for the
+// ndjson output format.
+func newRecordWriter(stdout bool) (*recordWriter, error) {
+	if stdout {
+		return &recordWriter{out: bufio.NewWriter(os.Stdout)}, nil
+	}
+
+	file, err := createFile("ndjson")
+	if err != nil {
+		return nil, err
+	}
+	return &recordWriter{out: bufio.NewWriter(file), closer: file}, nil
+}
+
+// write writes a single record. The fields of v are placed alongside the type,
+// namespace and mount tags, so v must encode to a JSON object.
+func (w *recordWriter) write(header recordHeader, v interface{}) {
+	headerBytes, err := json.Marshal(header)
+	if err == nil {
+		var body []byte
+		body, err = json.Marshal(v)
+		if err == nil && len(body) > 2 {
+			headerBytes = append(headerBytes[:len(headerBytes)-1], ',')
+			headerBytes = append(headerBytes, body[1:]...)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return
+	}
+	if err != nil {
+		w.err = fmt.Errorf("json.Marshal: %w", err)
+		return
+	}
+	if _, err := w.out.Write(append(headerBytes, '\n')); err != nil {
+		w.err = fmt.Errorf("error writing record: %w", err)
+	}
+}
+
+// close flushes any buffered records and closes the output file.
+func (w *recordWriter) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.out.Flush(); err != nil && w.err == nil {
+		w.err = fmt.Errorf("error writing record: %w", err)
+	}
+	if w.closer != nil {
+		if err := w.closer.Close(); err != nil && w.err == nil {
+			w.err = fmt.Errorf("error closing output file: %w", err)
+		}
+	}
+	return w.err
+}
+
+// The record methods below define the record written for each kind of
+// inventory object. Nested objects, such as the roles of an auth mount or the
+// aliases of an entity, are written as records of their own, so that every
+// record is flat apart from simple lists.
+
+func (w *recordWriter) namespace(ns *namespaceInventory) {
+	record := struct {
+		Name         string     `json:"name,omitempty"`
+		Parent       string     `json:"parent,omitempty"`
+		Usage        *usageData `json:"usage,omitempty"`
+		PoliciesOnly bool       `json:"policiesOnly,omitempty"`
+	}{Name: ns.Name, Parent: ns.Parent, PoliciesOnly: ns.PoliciesOnly}
+	if ns.Usage != (usageData{}) {
+		record.Usage = &ns.Usage
+	}
+	w.write(recordHeader{Type: "namespace", Namespace: ns.Name}, record)
+}
+
+// authMount writes the auth mount followed by its roles, users, groups and
+// certs, told apart by their kind.
+func (w *recordWriter) authMount(ns *namespaceInventory, am authMount) {
+	w.write(recordHeader{Type: "authMount", Namespace: ns.Name}, struct {
+		Path string `json:"path,omitempty"`
+		Type string `json:"type,omitempty"`
+	}{am.Path, am.Type})
+
+	for _, kind := range []struct {
+		name  string
+		items []authRole
+	}{{"role", am.Roles}, {"user", am.Users}, {"group", am.Groups}, {"cert", am.Certs}} {
+		for _, item := range kind.items {
+			w.write(recordHeader{Type: "authRole", Namespace: ns.Name, Mount: am.Path}, struct {
+				Kind string `json:"kind"`
+				authRole
+			}{kind.name, item})
+		}
+	}
+}
+
+// secretsEngine writes the secrets engine followed by its roles. Its secrets
+// are written separately.
+func (w *recordWriter) secretsEngine(ns *namespaceInventory, engine *secretsEngine) {
+	w.write(recordHeader{Type: "secretsEngine", Namespace: ns.Name}, struct {
+		Path      string `json:"path,omitempty"`
+		Type      string `json:"type,omitempty"`
+		Version   string `json:"version,omitempty"`
+		ItemCount int    `json:"itemCount,omitempty"`
+	}{engine.Path, engine.Type, engine.Version, engine.ItemCount})
+
+	for _, role := range engine.Roles {
+		w.write(recordHeader{Type: "role", Namespace: ns.Name, Mount: engine.Path}, struct {
+			Name string `json:"name"`
+		}{role})
+	}
+}
+
+func (w *recordWriter) secret(ns *namespaceInventory, engine *secretsEngine, secret staticSecret) {
+	w.write(recordHeader{Type: "secret", Namespace: ns.Name, Mount: engine.Path}, secret)
+}
+
+// entity writes the entity followed by its aliases, which carry the ID of the
+// entity they belong to.
+func (w *recordWriter) entity(ns *namespaceInventory, e entity) {
+	aliases := e.Aliases
+	e.Aliases = nil
+	w.write(recordHeader{Type: "entity", Namespace: ns.Name}, e)
+
+	for _, a := range aliases {
+		w.write(recordHeader{Type: "alias", Namespace: ns.Name}, struct {
+			EntityID string `json:"entityId"`
+			alias
+		}{e.ID, a})
+	}
+}
+
+func (w *recordWriter) group(ns *namespaceInventory, g identityGroup) {
+	w.write(recordHeader{Type: "group", Namespace: ns.Name}, g)
+}
+
+func (w *recordWriter) policy(ns *namespaceInventory, p policy) {
+	w.write(recordHeader{Type: "policy", Namespace: ns.Name}, p)
+}
+
+// usage writes the client counts of the whole cluster. The client counts of
+// each namespace are part of its namespace record.
+func (w *recordWriter) usage(u usageData) {
+	w.write(recordHeader{Type: "usage"}, u)
+}
+
+// errors writes one record per error. Errors that do not belong to a
+// namespace are written with an empty namespace.
+func (w *recordWriter) errors(namespace string, errs []string) {
+	for _, err := range errs {
+		w.write(recordHeader{Type: "error", Namespace: namespace}, struct {
+			Error string `json:"error"`
+		}{err})
+	}
+}
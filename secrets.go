@@ -7,19 +7,38 @@ import (
 	"sync"
 
 	"github.com/czembower/vault-auditor/utils"
-	"github.com/hashicorp/go-secure-stdlib/strutil"
 )
 
 type staticSecret struct {
-	Path           string      `json:"path,omitempty"`
-	CurrentVersion json.Number `json:"currentVersion,omitempty"`
-	CreationTime   string      `json:"creationTime,omitempty"`
-	UpdatedTime    string      `json:"updatedTime,omitempty"`
-	Policies       []string    `json:"policies,omitempty"`
-	Roles          []string    `json:"roles,omitempty"`
+	Path             string         `json:"path,omitempty"`
+	CurrentVersion   json.Number    `json:"currentVersion,omitempty"`
+	CreationTime     string         `json:"creationTime,omitempty"`
+	UpdatedTime      string         `json:"updatedTime,omitempty"`
+	Policies         []string       `json:"policies,omitempty"`
+	ReadPolicies     []string       `json:"readPolicies,omitempty"`
+	WritePolicies    []string       `json:"writePolicies,omitempty"`
+	DeletePolicies   []string       `json:"deletePolicies,omitempty"`
+	MetadataPolicies []string       `json:"metadataPolicies,omitempty"`
+	UndeletePolicies []string       `json:"undeletePolicies,omitempty"`
+	DestroyPolicies  []string       `json:"destroyPolicies,omitempty"`
+	Access           []secretAccess `json:"access,omitempty"`
+	Roles            []string       `json:"roles,omitempty"`
 }
 
-func (ns *namespaceInventory) scanEngines(c *clientConfig, i *vaultInventory) {
+// secretAccess records the effective capabilities a single policy grants on a
+// secret. Capabilities apply to the secret itself (the data/ path for KV v2),
+// while the remaining fields cover the KV v2 metadata and version management
+// paths.
+type secretAccess struct {
+	Policy               string   `json:"policy,omitempty"`
+	Capabilities         []string `json:"capabilities,omitempty"`
+	MetadataCapabilities []string `json:"metadataCapabilities,omitempty"`
+	DeleteCapabilities   []string `json:"deleteCapabilities,omitempty"`
+	UndeleteCapabilities []string `json:"undeleteCapabilities,omitempty"`
+	DestroyCapabilities  []string `json:"destroyCapabilities,omitempty"`
+}
+
+func (ns *namespaceInventory) scanEngines(c *clientConfig) {
 	namespacePath := utils.SetNamespacePath(ns.Name)
 	wg := sync.WaitGroup{}
 	mu := sync.Mutex{}
@@ -37,6 +56,9 @@ func (ns *namespaceInventory) scanEngines(c *clientConfig, i *vaultInventory) {
 		go func(seIdx int, engine *secretsEngine) {
 			defer wg.Done()
 			defer func() { <-sem }()
+			if c.state.restoreEngine(ns.Name, engine) {
+				return
+			}
 			localErrors := []string{}
 
 			defer func() {
@@ -91,21 +113,19 @@ func (ns *namespaceInventory) scanEngines(c *clientConfig, i *vaultInventory) {
 					engine.Version = "1"
 					path = strings.TrimSuffix(namespacePath+engine.Path, "/")
 				}
-				if c.ListSecrets {
-					if c.TargetEngine != "" {
-						target := strings.Split(c.TargetEngine, "/")
-						if ns.Name != target[0] || ns.SecretsEngines[seIdx].Path != target[1]+"/" {
-							return
-						}
-					}
-					ns.walkKvPath(seIdx, path, c, i)
+				if c.ListSecrets && (c.TargetEngine == "" || isTargetEngine(c.TargetEngine, ns.Name, engine)) {
+					ns.walkKvPath(seIdx, path, c, &localErrors)
 				}
 			}
 
 			mu.Lock()
-			engine.ItemCount = len(engine.Secrets)
+			if c.stream == nil {
+				engine.ItemCount = len(engine.Secrets)
+			}
 			ns.Errors = append(ns.Errors, localErrors...)
 			mu.Unlock()
+			c.state.completeEngine(ns.Name, engine, localErrors)
+			c.stream.secretsEngine(ns, engine)
 
 		}(seIdx, engine)
 	}
@@ -113,77 +133,246 @@ func (ns *namespaceInventory) scanEngines(c *clientConfig, i *vaultInventory) {
 	wg.Wait()
 }
 
-func (ns *namespaceInventory) walkKvPath(seIdx int, basepath string, c *clientConfig, i *vaultInventory) []staticSecret {
+// walkKvPath recursively lists the KV path, returning the secrets found
+// beneath it. Directories that were listed without errors are checkpointed,
+// and taken from the checkpoint instead of being listed again when an
+// interrupted scan is resumed.
+func (ns *namespaceInventory) walkKvPath(seIdx int, basepath string, c *clientConfig, errs *[]string) []staticSecret {
 	var kvPaths []staticSecret
+	engine := &ns.SecretsEngines[seIdx]
+
+	dir, ok := c.state.kvDir(ns.Name, engine.Path, basepath)
+	if !ok {
+		dir, ok = ns.listKvDir(engine, basepath, c, errs)
+		if ok {
+			c.state.saveKVDir(ns.Name, engine.Path, basepath, dir)
+		}
+	}
+
+	for _, kvPathString := range dir.Keys {
+		if secret, ok := dir.Secrets[kvPathString]; ok {
+			// streamed secrets are written out right away instead of
+			// being kept in the inventory
+			if c.stream != nil {
+				c.stream.secret(ns, engine, secret)
+				engine.ItemCount++
+				continue
+			}
+			kvPaths = append(kvPaths, secret)
+		} else if strings.HasSuffix(kvPathString, "/") {
+			kvPathString = strings.TrimSuffix(kvPathString, "/")
+			if !c.includeSecretDir(ns.Name, kvLogicalPath(ns.Name, engine, basepath+"/"+kvPathString)+"/") {
+				continue
+			}
+			kvPaths = append(kvPaths, ns.walkKvPath(seIdx, basepath+"/"+kvPathString, c, errs)...)
+		}
+	}
+
+	engine.Secrets = kvPaths
+	return kvPaths
+}
+
+// listKvDir lists a single KV directory, reading the metadata of the secrets
+// found directly within it. The returned bool is false if any request failed.
+func (ns *namespaceInventory) listKvDir(engine *secretsEngine, basepath string, c *clientConfig, errs *[]string) (kvDirState, bool) {
+	dir := kvDirState{Secrets: map[string]staticSecret{}}
 
 	listResp, err := c.Client.List(c.Ctx, basepath)
 	if err != nil {
-		ns.Errors = append(ns.Errors, fmt.Sprintf("error listing KV path %s: %v", basepath, err))
-	} else {
-		for _, kvPath := range listResp.Data["keys"].([]interface{}) {
-			kvPathString := kvPath.(string)
-			var secret staticSecret
-			if !strings.HasSuffix(kvPathString, "/") {
-				if strings.Contains(basepath, "/metadata") {
-					secretMetadata, err := c.Client.Read(c.Ctx, basepath+"/"+kvPathString)
-					if err != nil {
-						ns.Errors = append(ns.Errors, fmt.Sprintf("error reading KV metadata for %s: %v", basepath+"/"+kvPathString, err))
-					} else {
-						secret.CurrentVersion = secretMetadata.Data["current_version"].(json.Number)
-						secret.CreationTime = secretMetadata.Data["created_time"].(string)
-						secret.UpdatedTime = secretMetadata.Data["updated_time"].(string)
-					}
-				}
-				secret.Path = strings.Replace(basepath+"/"+kvPathString, "/metadata", "", 1)
-				for _, policy := range ns.Policies {
-					for _, policyPath := range policy.Paths {
-						match := checkForPolicyMatch(ns.Name, policyPath, basepath+"/"+kvPathString)
-						if match {
-							secret.Policies = append(secret.Policies, policy.Name)
-						}
-					}
-				}
-				if ns.Name != "root" {
-					for _, namespace := range i.Namespaces {
-						if namespace.Name == "root" {
-							for _, policy := range namespace.Policies {
-								for _, policyPath := range policy.Paths {
-									match := checkForPolicyMatch(namespace.Name, policyPath, basepath+"/"+kvPathString)
-									if match {
-										secret.Policies = append(secret.Policies, policy.Name+" (root)")
-									}
-								}
-							}
-						}
-					}
-				}
-				for _, authMount := range ns.AuthMounts {
-					for _, role := range authMount.Roles {
-						for _, policy := range role.Policies {
-							if utils.StringInSlice(policy, secret.Policies) {
-								secret.Roles = append(secret.Roles, role.Name)
-							}
-						}
-					}
-				}
-				kvPaths = append(kvPaths, secret)
+		*errs = append(*errs, fmt.Sprintf("error listing KV path %s: %v", basepath, err))
+		return dir, false
+	}
+
+	complete := true
+	for _, kvPath := range listResp.Data["keys"].([]interface{}) {
+		kvPathString := kvPath.(string)
+		dir.Keys = append(dir.Keys, kvPathString)
+		if strings.HasSuffix(kvPathString, "/") {
+			continue
+		}
+
+		var secret staticSecret
+		secret.Path = kvLogicalPath(ns.Name, engine, basepath+"/"+kvPathString)
+		if !c.includeSecret(ns.Name, secret.Path) {
+			continue
+		}
+		if engine.Version == "2" {
+			secretMetadata, err := c.Client.Read(c.Ctx, basepath+"/"+kvPathString)
+			if err != nil {
+				*errs = append(*errs, fmt.Sprintf("error reading KV metadata for %s: %v", basepath+"/"+kvPathString, err))
+				complete = false
 			} else {
-				kvPathString = strings.TrimSuffix(kvPathString, "/")
-				kvPaths = append(kvPaths, ns.walkKvPath(seIdx, basepath+"/"+kvPathString, c, i)...)
+				secret.CurrentVersion = secretMetadata.Data["current_version"].(json.Number)
+				secret.CreationTime = secretMetadata.Data["created_time"].(string)
+				secret.UpdatedTime = secretMetadata.Data["updated_time"].(string)
 			}
 		}
+		dir.Secrets[kvPathString] = secret
 	}
 
-	ns.SecretsEngines[seIdx].Secrets = kvPaths
-	return kvPaths
+	return dir, complete
 }
 
-func checkForPolicyMatch(namespace, policyPath, secretPath string) bool {
-	if namespace == "root" {
-		match := strutil.GlobbedStringsMatch("/"+policyPath, "/"+strings.Replace(secretPath, "/metadata", "", 1))
-		return match
+// isTargetEngine reports whether the engine is the one selected with
+// -targetEngine, given as the full path of the engine including its namespace,
+// e.g. "team-a/prod/kv". Engines in the root namespace may be given with or
+// without a "root/" prefix.
+func isTargetEngine(target, namespace string, engine *secretsEngine) bool {
+	target = strings.TrimPrefix(strings.Trim(target, "/"), "root/")
+	return utils.SetNamespacePath(namespace)+engine.Path == target+"/"
+}
+
+// mapSecretsAccess maps the access granted to every static secret of the
+// namespace, replacing the results of any previous analysis.
+func (ns *namespaceInventory) mapSecretsAccess(i *vaultInventory) {
+	for seIdx := range ns.SecretsEngines {
+		engine := &ns.SecretsEngines[seIdx]
+		for sIdx := range engine.Secrets {
+			secret := &engine.Secrets[sIdx]
+			*secret = staticSecret{
+				Path:           secret.Path,
+				CurrentVersion: secret.CurrentVersion,
+				CreationTime:   secret.CreationTime,
+				UpdatedTime:    secret.UpdatedTime,
+			}
+			ns.mapSecretAccess(secret, engine, i)
+		}
+	}
+}
+
+// mapSecretAccess evaluates the policies of the secret's namespace and its
+// ancestors against the API paths of the secret, recording which policies
+// grant each kind of access, and which auth roles carry those policies.
+func (ns *namespaceInventory) mapSecretAccess(secret *staticSecret, engine *secretsEngine, i *vaultInventory) {
+	apiPaths := kvAPIPaths(ns.Name, engine, secret.Path)
+
+	addAccess := func(name string, rules []scopedRule) {
+		access, matched := resolveSecretAccess(rules, apiPaths)
+		if !matched {
+			return
+		}
+		access.Policy = name
+		secret.Access = append(secret.Access, access)
+
+		if access.grantsAny() {
+			secret.Policies = append(secret.Policies, name)
+		}
+		if access.allows(kvData, "read") {
+			secret.ReadPolicies = append(secret.ReadPolicies, name)
+		}
+		if access.allows(kvData, "create", "update", "patch") {
+			secret.WritePolicies = append(secret.WritePolicies, name)
+		}
+		if access.allows(kvData, "delete") || access.allows(kvDelete, "update") {
+			secret.DeletePolicies = append(secret.DeletePolicies, name)
+		}
+		if access.allows(kvMetadata, "read") {
+			secret.MetadataPolicies = append(secret.MetadataPolicies, name)
+		}
+		if access.allows(kvUndelete, "update") {
+			secret.UndeletePolicies = append(secret.UndeletePolicies, name)
+		}
+		if access.allows(kvDestroy, "update") || access.allows(kvMetadata, "delete") {
+			secret.DestroyPolicies = append(secret.DestroyPolicies, name)
+		}
+	}
+
+	for _, policy := range ns.Policies {
+		addAccess(policy.Name, policyRules(ns.Name, policy))
+	}
+	for idx := range i.Namespaces {
+		namespace := &i.Namespaces[idx]
+		if !isAncestorNamespace(namespace.Name, ns.Name) {
+			continue
+		}
+		for _, policy := range namespace.Policies {
+			addAccess(policy.Name+" ("+namespace.Name+")", policyRules(namespace.Name, policy))
+		}
+	}
+
+	for _, authMount := range ns.AuthMounts {
+		for _, role := range authMount.Roles {
+			for _, policy := range role.Policies {
+				if utils.StringInSlice(policy, secret.Policies) && !utils.StringInSlice(role.Name, secret.Roles) {
+					secret.Roles = append(secret.Roles, role.Name)
+				}
+			}
+		}
+	}
+}
+
+// resolveSecretAccess evaluates the given rules against each of the API paths
+// of a secret. The returned bool is false if no rule matched any of the paths.
+func resolveSecretAccess(rules []scopedRule, apiPaths map[string]string) (secretAccess, bool) {
+	var access secretAccess
+	matched := false
+
+	for operation, path := range apiPaths {
+		capabilities := resolveCapabilities(rules, path)
+		if capabilities == nil {
+			continue
+		}
+		matched = true
+		switch operation {
+		case kvData:
+			access.Capabilities = capabilities
+		case kvMetadata:
+			access.MetadataCapabilities = capabilities
+		case kvDelete:
+			access.DeleteCapabilities = capabilities
+		case kvUndelete:
+			access.UndeleteCapabilities = capabilities
+		case kvDestroy:
+			access.DestroyCapabilities = capabilities
+		}
+	}
+
+	return access, matched
+}
+
+// capabilities returns the capabilities granted on the given KV API path.
+func (a secretAccess) capabilities(operation string) []string {
+	switch operation {
+	case kvData:
+		return a.Capabilities
+	case kvMetadata:
+		return a.MetadataCapabilities
+	case kvDelete:
+		return a.DeleteCapabilities
+	case kvUndelete:
+		return a.UndeleteCapabilities
+	case kvDestroy:
+		return a.DestroyCapabilities
 	}
-	match := strutil.GlobbedStringsMatch("/"+namespace+"/"+policyPath, "/"+strings.Replace(secretPath, "/metadata", "", 1))
+	return nil
+}
+
+// allows reports whether any of the wanted capabilities is granted, and not
+// denied, on the given KV API path.
+func (a secretAccess) allows(operation string, wanted ...string) bool {
+	capabilities := a.capabilities(operation)
+	return !utils.StringInSlice("deny", capabilities) && grantsCapability(capabilities, wanted...)
+}
+
+// grantsAny reports whether any operation at all is allowed on the secret.
+func (a secretAccess) grantsAny() bool {
+	return a.allows(kvData, "create", "read", "update", "patch", "delete", "list") ||
+		a.allows(kvMetadata, "create", "read", "update", "patch", "delete", "list") ||
+		a.allows(kvDelete, "update") || a.allows(kvUndelete, "update") || a.allows(kvDestroy, "update")
+}
 
-	return match
+// checkForPolicyMatch reports whether a policy path defined in the given
+// namespace matches the full path of a secret.
+func checkForPolicyMatch(namespace, policyPath, secretPath string) bool {
+	return policyPathMatches(utils.SetNamespacePath(namespace)+policyPath, secretPath)
+}
+
+// isAncestorNamespace reports whether ancestor is a parent, grandparent, etc.
+// of namespace. Policies defined in an ancestor namespace may grant access to
+// paths within its descendants.
+func isAncestorNamespace(ancestor, namespace string) bool {
+	if ancestor == namespace || namespace == "root" {
+		return false
+	}
+	return ancestor == "root" || strings.HasPrefix(namespace, ancestor+"/")
 }
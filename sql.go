@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sqlTablePrefix is prepended to the name of every table in the database, so
+// that the tables of vault-auditor cannot collide with those of other
+// applications sharing the database.
+const sqlTablePrefix = "vault_auditor_"
+
+// sqlTableName returns the quoted name of a table in the database.
+func sqlTableName(dialect sqlDialect, name string) string {
+	return dialect.quote(sqlTablePrefix + name)
+}
+
+// sqlDialect holds what differs between the supported SQL databases. The
+// schema, the migrations and the way a scan is loaded are shared.
+type sqlDialect interface {
+	columnType(column tableColumn) string
+	// primaryKey returns the table definitions making the given columns the
+	// primary key of a table.
+	primaryKey(columns []tableColumn) []string
+	quote(identifier string) string
+	placeholder(n int) string
+	// lockMigrations keeps concurrent runs from applying the same migration,
+	// until the returned function is called.
+	lockMigrations(ctx context.Context, conn *sql.Conn) (unlock func(), err error)
+	// insertScan runs the insert into the scans table and returns the
+	// generated scan_id.
+	insertScan(txn *sql.Tx, query string, args ...interface{}) (int64, error)
+	// load bulk loads the rows of an inventory table, tagged with the scan
+	// they belong to.
+	load(txn *sql.Tx, scanID int64, table *inventoryTable) error
+}
+
+// sqlOutput loads the inventory into the database as a new scan, within a
+// single transaction, after bringing the schema up to date.
+func (i *vaultInventory) sqlOutput(driver, dataSourceName string, dialect sqlDialect) error {
+	db, err := sql.Open(driver, dataSourceName)
+	if err != nil {
+		return fmt.Errorf("sql.Open: %w", err)
+	}
+	defer db.Close()
+
+	if err = db.Ping(); err != nil {
+		return fmt.Errorf("db.Ping: %w", err)
+	}
+	fmt.Println("Connected to database.")
+
+	if err := migrateSchema(db, dialect); err != nil {
+		return err
+	}
+
+	txn, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer txn.Rollback()
+
+	columns := scansTable.columnNames()[1:]
+	scanID, err := dialect.insertScan(txn, insertStatement(dialect, scansTable.Name, columns, 1), i.scanValues()...)
+	if err != nil {
+		return fmt.Errorf("error recording scan: %w", err)
+	}
+
+	for _, table := range i.inventoryTables() {
+		if err := dialect.load(txn, scanID, table); err != nil {
+			return err
+		}
+	}
+
+	if err := txn.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	fmt.Printf("Recorded scan %d.\n", scanID)
+
+	return nil
+}
+
+// migrateSchema applies the schema migrations the database has not seen yet,
+// each in a transaction of its own, while holding the dialect's migration
+// lock.
+func migrateSchema(db *sql.DB, dialect sqlDialect) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error connecting to database: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, createTableStatement(dialect, &inventoryTable{
+		Name:    "schema_migrations",
+		Key:     1,
+		Columns: []tableColumn{integer("version"), text("description"), timestamp("applied_at")},
+	}, true))
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations table: %w", err)
+	}
+
+	unlock, err := dialect.lockMigrations(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("error locking schema_migrations table: %w", err)
+	}
+	defer unlock()
+
+	for _, migration := range schemaMigrations {
+		if err := applyMigration(ctx, conn, dialect, migration); err != nil {
+			return fmt.Errorf("error applying schema migration %d: %w", migration.Version, err)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, dialect sqlDialect, migration schemaMigration) error {
+	txn, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	var applied int
+	err = txn.QueryRow(`SELECT count(*) FROM `+sqlTableName(dialect, "schema_migrations")+` WHERE version = `+dialect.placeholder(1), migration.Version).Scan(&applied)
+	if err != nil || applied > 0 {
+		return err
+	}
+
+	for _, table := range migration.Create {
+		if _, err := txn.Exec(createTableStatement(dialect, table, false)); err != nil {
+			return err
+		}
+		if table.Key == 0 && table.ScanID {
+			_, err := txn.Exec("CREATE INDEX " + sqlTableName(dialect, table.Name+"_scan_id") + " ON " + sqlTableName(dialect, table.Name) + " (scan_id)")
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err = txn.Exec(insertStatement(dialect, "schema_migrations", []string{"version", "description", "applied_at"}, 1),
+		migration.Version, migration.Description, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	return txn.Commit()
+}
+
+// createTableStatement returns the statement creating the table. Tables with
+// ScanID set get a leading scan_id column referencing the scans table, which
+// is also the first column of their primary key.
+func createTableStatement(dialect sqlDialect, table *inventoryTable, ifNotExists bool) string {
+	var definitions []string
+	var key []tableColumn
+	if table.ScanID {
+		definitions = append(definitions, dialect.quote("scan_id")+" "+dialect.columnType(integer("scan_id"))+" NOT NULL")
+		key = append(key, integer("scan_id"))
+	}
+	for idx, column := range table.Columns {
+		definition := dialect.quote(column.Name) + " " + dialect.columnType(column)
+		if idx < table.Key {
+			definition += " NOT NULL"
+			key = append(key, column)
+		}
+		definitions = append(definitions, definition)
+	}
+	if table.Key > 0 {
+		definitions = append(definitions, dialect.primaryKey(key)...)
+	}
+	if table.ScanID {
+		definitions = append(definitions, "FOREIGN KEY ("+dialect.quote("scan_id")+") REFERENCES "+sqlTableName(dialect, scansTable.Name)+" ("+dialect.quote("scan_id")+") ON DELETE CASCADE")
+	}
+
+	create := "CREATE TABLE "
+	if ifNotExists {
+		create += "IF NOT EXISTS "
+	}
+	return create + sqlTableName(dialect, table.Name) + " (\n\t" + strings.Join(definitions, ",\n\t") + "\n)"
+}
+
+// primaryKeyConstraint returns the PRIMARY KEY constraint on the given
+// columns.
+func primaryKeyConstraint(dialect sqlDialect, columns []tableColumn) string {
+	quoted := make([]string, len(columns))
+	for idx, column := range columns {
+		quoted[idx] = dialect.quote(column.Name)
+	}
+	return "PRIMARY KEY (" + strings.Join(quoted, ", ") + ")"
+}
+
+// insertStatement returns an insert of the given number of rows into the
+// columns of the table.
+func insertStatement(dialect sqlDialect, tableName string, columns []string, rows int) string {
+	quoted := make([]string, len(columns))
+	for idx, column := range columns {
+		quoted[idx] = dialect.quote(column)
+	}
+
+	var values []string
+	n := 0
+	for row := 0; row < rows; row++ {
+		placeholders := make([]string, len(columns))
+		for idx := range columns {
+			n++
+			placeholders[idx] = dialect.placeholder(n)
+		}
+		values = append(values, "("+strings.Join(placeholders, ", ")+")")
+	}
+
+	return "INSERT INTO " + sqlTableName(dialect, tableName) + " (" + strings.Join(quoted, ", ") + ") VALUES " + strings.Join(values, ", ")
+}
+
+// insertRows loads the rows of the table with a prepared single-row insert.
+func insertRows(txn *sql.Tx, dialect sqlDialect, scanID int64, table *inventoryTable) error {
+	stmt, err := txn.Prepare(insertStatement(dialect, table.Name, append([]string{"scan_id"}, table.columnNames()...), 1))
+	if err != nil {
+		return fmt.Errorf("error preparing insert into %s: %w", table.Name, err)
+	}
+	defer stmt.Close()
+
+	for _, row := range table.Rows {
+		if _, err := stmt.Exec(append([]interface{}{scanID}, row...)...); err != nil {
+			return fmt.Errorf("error inserting into %s: %w", table.Name, err)
+		}
+	}
+
+	return stmt.Close()
+}
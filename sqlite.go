@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+type sqliteDialect struct{}
+
+// Integer primary keys of SQLite tables are generated on insert, so the
+// scan_id column of the scans table is a plain INTEGER.
+var sqliteColumnTypes = map[columnType]string{
+	textColumn:    "TEXT",
+	integerColumn: "INTEGER",
+	timeColumn:    "TIMESTAMP",
+	serialColumn:  "INTEGER",
+}
+
+// sqliteOutput loads the inventory into the SQLite database file given by a
+// sqlite://path connection string, creating it if needed. Foreign keys are
+// enforced, so that deleting a scan deletes its rows, and transactions take
+// the write lock up front, so that concurrent runs wait for each other.
+func (i *vaultInventory) sqliteOutput(sqlConnectionString string) error {
+	fileName := strings.TrimPrefix(sqlConnectionString, "sqlite://")
+	if idx := strings.Index(fileName, "?"); idx >= 0 {
+		fileName = fileName[:idx]
+	}
+	dataSourceName := fileName + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)&_txlock=immediate&_time_format=sqlite"
+
+	return i.sqlOutput("sqlite", dataSourceName, sqliteDialect{})
+}
+
+func (sqliteDialect) columnType(column tableColumn) string {
+	return sqliteColumnTypes[column.Type]
+}
+
+func (d sqliteDialect) primaryKey(columns []tableColumn) []string {
+	return []string{primaryKeyConstraint(d, columns)}
+}
+
+func (sqliteDialect) quote(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+func (sqliteDialect) placeholder(n int) string {
+	return "?"
+}
+
+// lockMigrations is a no-op, as each migration transaction holds the database
+// write lock.
+func (sqliteDialect) lockMigrations(ctx context.Context, conn *sql.Conn) (func(), error) {
+	return func() {}, nil
+}
+
+func (sqliteDialect) insertScan(txn *sql.Tx, query string, args ...interface{}) (int64, error) {
+	result, err := txn.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (d sqliteDialect) load(txn *sql.Tx, scanID int64, table *inventoryTable) error {
+	return insertRows(txn, d, scanID, table)
+}
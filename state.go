@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const checkpointInterval = 30 * time.Second
+
+// scanState records the progress of a scan so that an interrupted scan can be
+// resumed. Progress is recorded at three levels: the namespace list with
+// mounts and policies, which is collected up front, each fully scanned
+// namespace, and within namespaces that are still being scanned, each fully
+// scanned secrets engine and each listed KV directory. Checkpointed objects
+// are stored as JSON when they are recorded, as the inventory they are taken
+// from keeps changing while the scan goes on.
+//
+// All methods are no-ops on a nil *scanState, so scanners need not check
+// whether checkpointing is enabled.
+type scanState struct {
+	Scan       json.RawMessage                    `json:"scan"`
+	Namespaces json.RawMessage                    `json:"namespaces,omitempty"`
+	Errors     []string                           `json:"errors,omitempty"`
+	Completed  map[string]json.RawMessage         `json:"completed,omitempty"`
+	Engines    map[string]map[string]*engineState `json:"engines,omitempty"`
+
+	fileName string
+	closed   bool
+	mu       sync.Mutex
+}
+
+// engineState holds a completed secrets engine, or the KV directories listed
+// so far for an engine that is still being scanned.
+type engineState struct {
+	Engine json.RawMessage       `json:"engine,omitempty"`
+	KVDirs map[string]kvDirState `json:"kvDirs,omitempty"`
+}
+
+// kvDirState is a listed KV directory: its keys in listing order, and the
+// secrets found directly within it. Subdirectories are recorded separately.
+type kvDirState struct {
+	Keys    []string                `json:"keys,omitempty"`
+	Secrets map[string]staticSecret `json:"secrets,omitempty"`
+}
+
+// scanSettings are the settings that determine what a scan collects. A state
+// file can only be resumed with the same settings it was written with.
+type scanSettings struct {
+	Addr                  string   `json:"addr"`
+	BaseNamespace         string   `json:"baseNamespace"`
+	ListSecrets           bool     `json:"listSecrets"`
+	TargetEngine          string   `json:"targetEngine,omitempty"`
+	IncludeNamespaces     []string `json:"includeNamespaces,omitempty"`
+	ExcludeNamespaces     []string `json:"excludeNamespaces,omitempty"`
+	IncludeMounts         []string `json:"includeMounts,omitempty"`
+	ExcludeMounts         []string `json:"excludeMounts,omitempty"`
+	IncludeMountTypes     []string `json:"includeMountTypes,omitempty"`
+	ExcludeMountTypes     []string `json:"excludeMountTypes,omitempty"`
+	IncludeAuthMounts     []string `json:"includeAuthMounts,omitempty"`
+	ExcludeAuthMounts     []string `json:"excludeAuthMounts,omitempty"`
+	IncludeSecretsEngines []string `json:"includeSecretsEngines,omitempty"`
+	ExcludeSecretsEngines []string `json:"excludeSecretsEngines,omitempty"`
+	IncludeSecretPaths    []string `json:"includeSecretPaths,omitempty"`
+	ExcludeSecretPaths    []string `json:"excludeSecretPaths,omitempty"`
+}
+
+// loadScanState reads the state file left behind by an interrupted scan, or
+// starts a new one if the file does not exist.
+func loadScanState(fileName string, c *clientConfig) (*scanState, error) {
+	settings, err := json.Marshal(scanSettings{
+		Addr:                  c.Addr,
+		BaseNamespace:         c.BaseNamespace,
+		ListSecrets:           c.ListSecrets,
+		TargetEngine:          c.TargetEngine,
+		IncludeNamespaces:     c.IncludeNamespaces,
+		ExcludeNamespaces:     c.ExcludeNamespaces,
+		IncludeMounts:         c.IncludeMounts,
+		ExcludeMounts:         c.ExcludeMounts,
+		IncludeMountTypes:     c.IncludeMountTypes,
+		ExcludeMountTypes:     c.ExcludeMountTypes,
+		IncludeAuthMounts:     c.IncludeAuthMounts,
+		ExcludeAuthMounts:     c.ExcludeAuthMounts,
+		IncludeSecretsEngines: c.IncludeSecretsEngines,
+		ExcludeSecretsEngines: c.ExcludeSecretsEngines,
+		IncludeSecretPaths:    c.IncludeSecretPaths,
+		ExcludeSecretPaths:    c.ExcludeSecretPaths,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	s := &scanState{
+		Scan:      settings,
+		Completed: map[string]json.RawMessage{},
+		Engines:   map[string]map[string]*engineState{},
+		fileName:  fileName,
+	}
+
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file: %w", err)
+	}
+
+	var saved scanState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("error parsing state file %s: %w", fileName, err)
+	}
+	if string(saved.Scan) != string(settings) {
+		return nil, fmt.Errorf("state file %s was written by a scan with different settings, remove it to start a new scan", fileName)
+	}
+	if saved.Completed != nil {
+		s.Completed = saved.Completed
+	}
+	if saved.Engines != nil {
+		s.Engines = saved.Engines
+	}
+	s.Namespaces = saved.Namespaces
+	s.Errors = saved.Errors
+	log.Printf("resuming scan from state file %s", fileName)
+
+	return s, nil
+}
+
+// restoreNamespaces loads the namespace list, mounts and policies collected
+// before the scan was interrupted, reporting whether there were any.
+func (s *scanState) restoreNamespaces(i *vaultInventory) bool {
+	if s == nil || s.Namespaces == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := json.Unmarshal(s.Namespaces, &i.Namespaces); err != nil {
+		return false
+	}
+	i.Errors = append(i.Errors, s.Errors...)
+	return true
+}
+
+// saveNamespaces records the namespace list, mounts and policies, unless any
+// of them failed to be read, in which case they are collected again when the
+// scan is resumed.
+func (s *scanState) saveNamespaces(i *vaultInventory) {
+	if s == nil || len(i.Errors) > 0 {
+		return
+	}
+	for idx := range i.Namespaces {
+		if len(i.Namespaces[idx].Errors) > 0 {
+			return
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if data, err := json.Marshal(i.Namespaces); err == nil {
+		s.Namespaces = data
+		s.Errors = append([]string{}, i.Errors...)
+	}
+}
+
+// restoreNamespace replaces ns with its checkpoint if the namespace was
+// completely scanned, reporting whether it was.
+func (s *scanState) restoreNamespace(ns *namespaceInventory) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.Completed[ns.Name]
+	if !ok {
+		return false
+	}
+	var restored namespaceInventory
+	if err := json.Unmarshal(data, &restored); err != nil {
+		return false
+	}
+	*ns = restored
+	return true
+}
+
+// completeNamespace records a completely scanned namespace, superseding the
+// checkpoints of its secrets engines. A namespace with errors is not recorded,
+// so that its failed requests are retried when the scan is resumed, while the
+// checkpoints of its secrets engines are kept.
+func (s *scanState) completeNamespace(ns *namespaceInventory) {
+	if s == nil || len(ns.Errors) > 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if data, err := json.Marshal(ns); err == nil {
+		s.Completed[ns.Name] = data
+		delete(s.Engines, ns.Name)
+	}
+}
+
+// engineState returns the state of a secrets engine, creating it if needed.
+// The caller must hold s.mu.
+func (s *scanState) engineState(namespace, enginePath string) *engineState {
+	if s.Engines[namespace] == nil {
+		s.Engines[namespace] = map[string]*engineState{}
+	}
+	if s.Engines[namespace][enginePath] == nil {
+		s.Engines[namespace][enginePath] = &engineState{}
+	}
+	return s.Engines[namespace][enginePath]
+}
+
+// restoreEngine replaces engine with its checkpoint if the engine was
+// completely scanned, reporting whether it was.
+func (s *scanState) restoreEngine(namespace string, engine *secretsEngine) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	es := s.Engines[namespace][engine.Path]
+	if es == nil || es.Engine == nil {
+		return false
+	}
+	var restored secretsEngine
+	if err := json.Unmarshal(es.Engine, &restored); err != nil {
+		return false
+	}
+	*engine = restored
+	return true
+}
+
+// completeEngine records a completely scanned secrets engine, superseding the
+// checkpoints of its KV directories. An engine with errors is not recorded,
+// so that it is scanned again when the scan is resumed, replaying the KV
+// directories that were listed successfully.
+func (s *scanState) completeEngine(namespace string, engine *secretsEngine, errs []string) {
+	if s == nil || len(errs) > 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if data, err := json.Marshal(engine); err == nil {
+		es := s.engineState(namespace, engine.Path)
+		es.Engine = data
+		es.KVDirs = nil
+	}
+}
+
+// kvDir returns the checkpoint of a listed KV directory, given by the API
+// path it was listed at.
+func (s *scanState) kvDir(namespace, enginePath, path string) (kvDirState, bool) {
+	if s == nil {
+		return kvDirState{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	es := s.Engines[namespace][enginePath]
+	if es == nil {
+		return kvDirState{}, false
+	}
+	dir, ok := es.KVDirs[path]
+	return dir, ok
+}
+
+// saveKVDir records a listed KV directory.
+func (s *scanState) saveKVDir(namespace, enginePath, path string, dir kvDirState) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	es := s.engineState(namespace, enginePath)
+	if es.KVDirs == nil {
+		es.KVDirs = map[string]kvDirState{}
+	}
+	es.KVDirs[path] = dir
+}
+
+// save writes the state file, replacing it atomically so that an interruption
+// while writing does not lose the previous checkpoint.
+func (s *scanState) save() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	tmpFile := s.fileName + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+	if err := os.Rename(tmpFile, s.fileName); err != nil {
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+
+	return nil
+}
+
+// checkpoint saves the state file periodically, and once more before exiting
+// if the scan is interrupted. The returned function stops checkpointing.
+func (s *scanState) checkpoint() (stop func()) {
+	if s == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		ticker := time.NewTicker(checkpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.save(); err != nil {
+					log.Printf("checkpoint: %v", err)
+				}
+			case <-signals:
+				if err := s.save(); err != nil {
+					log.Fatalf("scan interrupted, unable to save progress: %v", err)
+				}
+				log.Fatalf("scan interrupted, progress saved to %s; run again with the same settings to resume", s.fileName)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// remove deletes the state file once the scan has completed and its output
+// has been written.
+func (s *scanState) remove() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if err := os.Remove(s.fileName); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error removing state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScanStateRoundTrip(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "vault-auditor.state")
+	c := &clientConfig{Addr: "https://vault:8200", BaseNamespace: "root", ListSecrets: true}
+
+	s, err := loadScanState(fileName, c)
+	if err != nil {
+		t.Fatalf("loadScanState: %v", err)
+	}
+
+	scanned := &vaultInventory{Namespaces: []namespaceInventory{
+		{Name: "root", Policies: []policy{{Name: "admin"}}},
+		{Name: "team-a", Parent: "root"},
+		{Name: "team-b", Parent: "root"},
+	}}
+	s.saveNamespaces(scanned)
+
+	// a completely scanned namespace, and one that failed
+	complete := scanned.Namespaces[0]
+	complete.AuthMounts = []authMount{{Path: "approle/", Type: "approle"}}
+	s.completeNamespace(&complete)
+	failed := scanned.Namespaces[2]
+	failed.Errors = []string{"error listing path team-b/identity/entity/id"}
+	s.completeNamespace(&failed)
+
+	// a completely scanned engine, one that failed, and the KV directories
+	// listed so far in an engine that is still being scanned
+	s.completeEngine("team-a", &secretsEngine{Path: "pki/", Type: "pki", Roles: []string{"web"}}, nil)
+	s.completeEngine("team-a", &secretsEngine{Path: "ssh/", Type: "ssh"}, []string{"error listing secrets engine `roles` path team-a/ssh/roles"})
+	s.saveKVDir("team-a", "kv/", "team-a/kv/metadata", kvDirState{
+		Keys:    []string{"app", "sub/"},
+		Secrets: map[string]staticSecret{"app": {Path: "team-a/kv/app"}},
+	})
+	s.saveKVDir("team-a", "kv/", "team-a/kv/metadata/sub", kvDirState{
+		Keys:    []string{"db"},
+		Secrets: map[string]staticSecret{"db": {Path: "team-a/kv/sub/db"}},
+	})
+
+	if err := s.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	resumed, err := loadScanState(fileName, c)
+	if err != nil {
+		t.Fatalf("loadScanState: %v", err)
+	}
+
+	i := &vaultInventory{}
+	if !resumed.restoreNamespaces(i) {
+		t.Fatal("restoreNamespaces found no namespaces")
+	}
+	if !reflect.DeepEqual(i.Namespaces, scanned.Namespaces) {
+		t.Errorf("restored namespaces = %+v, want %+v", i.Namespaces, scanned.Namespaces)
+	}
+
+	if !resumed.restoreNamespace(&i.Namespaces[0]) {
+		t.Error("completed namespace root was not restored")
+	} else if !reflect.DeepEqual(i.Namespaces[0], complete) {
+		t.Errorf("restored namespace = %+v, want %+v", i.Namespaces[0], complete)
+	}
+	if resumed.restoreNamespace(&i.Namespaces[1]) {
+		t.Error("namespace team-a was restored without being completed")
+	}
+	if resumed.restoreNamespace(&i.Namespaces[2]) {
+		t.Error("namespace team-b was restored although it had errors")
+	}
+
+	pki := secretsEngine{Path: "pki/", Type: "pki"}
+	if !resumed.restoreEngine("team-a", &pki) {
+		t.Error("completed engine pki/ was not restored")
+	} else if !reflect.DeepEqual(pki.Roles, []string{"web"}) {
+		t.Errorf("restored engine roles = %v, want [web]", pki.Roles)
+	}
+	if resumed.restoreEngine("team-a", &secretsEngine{Path: "ssh/", Type: "ssh"}) {
+		t.Error("engine ssh/ was restored although it had errors")
+	}
+
+	// the KV directories are replayed without any requests to Vault
+	ns := &namespaceInventory{Name: "team-a", SecretsEngines: []secretsEngine{{Path: "kv/", Type: "kv", Version: "2"}}}
+	var errs []string
+	secrets := ns.walkKvPath(0, "team-a/kv/metadata", &clientConfig{state: resumed}, &errs)
+	want := []staticSecret{{Path: "team-a/kv/app"}, {Path: "team-a/kv/sub/db"}}
+	if !reflect.DeepEqual(secrets, want) || len(errs) > 0 {
+		t.Errorf("walkKvPath = %+v, %v, want %+v", secrets, errs, want)
+	}
+
+	if err := resumed.remove(); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := os.Stat(fileName); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("state file still exists after remove: %v", err)
+	}
+}
+
+func TestScanStateNamespaceErrors(t *testing.T) {
+	s, err := loadScanState(filepath.Join(t.TempDir(), "vault-auditor.state"), &clientConfig{})
+	if err != nil {
+		t.Fatalf("loadScanState: %v", err)
+	}
+
+	s.saveNamespaces(&vaultInventory{
+		Namespaces: []namespaceInventory{{Name: "root", Errors: []string{"error listing path sys/policy"}}},
+	})
+	if s.restoreNamespaces(&vaultInventory{}) {
+		t.Error("namespaces with errors were recorded")
+	}
+
+	s.saveNamespaces(&vaultInventory{
+		Namespaces: []namespaceInventory{{Name: "root"}},
+		Errors:     []string{"error listing namespaces for namespace team-a"},
+	})
+	if s.restoreNamespaces(&vaultInventory{}) {
+		t.Error("namespaces were recorded although namespace discovery failed")
+	}
+}
+
+func TestScanStateSettingsMismatch(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "vault-auditor.state")
+
+	s, err := loadScanState(fileName, &clientConfig{Addr: "https://vault:8200", IncludeNamespaces: []string{"team-a"}})
+	if err != nil {
+		t.Fatalf("loadScanState: %v", err)
+	}
+	if err := s.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	for _, c := range []*clientConfig{
+		{Addr: "https://other:8200", IncludeNamespaces: []string{"team-a"}},
+		{Addr: "https://vault:8200", IncludeNamespaces: []string{"team-b"}},
+		{Addr: "https://vault:8200", IncludeNamespaces: []string{"team-a"}, ListSecrets: true},
+	} {
+		_, err := loadScanState(fileName, c)
+		if err == nil || !strings.Contains(err.Error(), "different settings") {
+			t.Errorf("loadScanState with settings %+v = %v, want a settings mismatch error", c, err)
+		}
+	}
+
+	if _, err := loadScanState(fileName, &clientConfig{Addr: "https://vault:8200", IncludeNamespaces: []string{"team-a"}}); err != nil {
+		t.Errorf("loadScanState with the same settings: %v", err)
+	}
+}
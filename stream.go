@@ -0,0 +1,77 @@
+package main
+
+// streamSink emits inventory records while the scan is running, so that
+// static secrets can be written out as they are found instead of being
+// retained in the inventory. The access granted to each secret is mapped
+// before it is written, as there is no later analysis step to do so.
+//
+// All methods are no-ops on a nil *streamSink, so scanners need not check
+// whether streaming is enabled.
+type streamSink struct {
+	records   *recordWriter
+	inventory *vaultInventory
+}
+
+// policies emits the policies of the namespace. The namespace record itself
+// is written once the scan completes, as it carries the client counts of the
+// namespace, which are only collected at the end.
+func (s *streamSink) policies(ns *namespaceInventory) {
+	if s == nil {
+		return
+	}
+	for _, p := range ns.Policies {
+		s.records.policy(ns, p)
+	}
+}
+
+// authMounts emits the auth mounts of the namespace along with their roles,
+// users, groups and certs.
+func (s *streamSink) authMounts(ns *namespaceInventory) {
+	if s == nil {
+		return
+	}
+	for _, am := range ns.AuthMounts {
+		s.records.authMount(ns, am)
+	}
+}
+
+// identities emits the entities and identity groups of the namespace. It is
+// called once the scan completes and group policies have been resolved, as
+// entities may belong to groups in any namespace.
+func (s *streamSink) identities(ns *namespaceInventory) {
+	if s == nil {
+		return
+	}
+	for _, e := range ns.Entities {
+		s.records.entity(ns, e)
+	}
+	for _, g := range ns.Groups {
+		s.records.group(ns, g)
+	}
+}
+
+// secretsEngine emits a secrets engine along with its roles. Its secrets have
+// already been emitted one by one.
+func (s *streamSink) secretsEngine(ns *namespaceInventory, engine *secretsEngine) {
+	if s == nil {
+		return
+	}
+	s.records.secretsEngine(ns, engine)
+}
+
+// secret maps the access granted to a static secret and emits it.
+func (s *streamSink) secret(ns *namespaceInventory, engine *secretsEngine, secret staticSecret) {
+	if s == nil {
+		return
+	}
+	ns.mapSecretAccess(&secret, engine, s.inventory)
+	s.records.secret(ns, engine, secret)
+}
+
+// errors emits the given errors, one record each.
+func (s *streamSink) errors(namespace string, errs []string) {
+	if s == nil {
+		return
+	}
+	s.records.errors(namespace, errs)
+}
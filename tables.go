@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// inventoryTable is one table of the relational view of the inventory, shared
+// by the table-based output formats. Rows reference the namespace and mount
+// path of the objects they belong to, so that tables can be joined. Values
+// are strings, int64s, time.Times or nil for missing values. The first Key
+// columns uniquely identify a row, if Key is non-zero. In the SQL schema,
+// tables with ScanID set start with a scan_id column referencing the scans
+// table, which is not part of Columns.
+type inventoryTable struct {
+	Name    string
+	Columns []tableColumn
+	Key     int
+	ScanID  bool
+	Rows    [][]interface{}
+}
+
+type columnType int
+
+const (
+	textColumn columnType = iota
+	integerColumn
+	timeColumn
+	serialColumn
+)
+
+type tableColumn struct {
+	Name string
+	Type columnType
+}
+
+func text(name string) tableColumn      { return tableColumn{name, textColumn} }
+func integer(name string) tableColumn   { return tableColumn{name, integerColumn} }
+func timestamp(name string) tableColumn { return tableColumn{name, timeColumn} }
+func serial(name string) tableColumn    { return tableColumn{name, serialColumn} }
+
+// columnNames returns the names of the table's columns.
+func (t *inventoryTable) columnNames() []string {
+	names := make([]string, len(t.Columns))
+	for idx, column := range t.Columns {
+		names[idx] = column.Name
+	}
+	return names
+}
+
+func (t *inventoryTable) add(values ...interface{}) {
+	t.Rows = append(t.Rows, values)
+}
+
+// inventoryTables flattens the inventory into tables, one per object type,
+// with nested lists such as policies and roles moved into tables of their
+// own.
+func (i *vaultInventory) inventoryTables() []*inventoryTable {
+	namespaces := &inventoryTable{Name: "namespaces", Key: 1, Columns: []tableColumn{text("namespace"), text("parent"), integer("policies_only")}}
+	usage := &inventoryTable{Name: "usage", Columns: []tableColumn{text("namespace"), integer("distinct_entities"), integer("clients"), integer("non_entity_clients"), integer("secret_syncs"), integer("acme_clients")}}
+	policies := &inventoryTable{Name: "policies", Key: 2, Columns: []tableColumn{text("namespace"), text("policy")}}
+	policyRules := &inventoryTable{Name: "policy_rules", Columns: []tableColumn{text("namespace"), text("policy"), text("path"), text("capabilities"), text("allowed_parameters"), text("denied_parameters"), text("required_parameters"), text("min_wrapping_ttl"), text("max_wrapping_ttl")}}
+	authMounts := &inventoryTable{Name: "auth_mounts", Key: 2, Columns: []tableColumn{text("namespace"), text("mount_path"), text("type")}}
+	authRoles := &inventoryTable{Name: "auth_roles", Key: 4, Columns: []tableColumn{text("namespace"), text("mount_path"), text("kind"), text("name")}}
+	rolePolicies := &inventoryTable{Name: "role_policies", Columns: []tableColumn{text("namespace"), text("mount_path"), text("kind"), text("name"), text("policy")}}
+	roleGroups := &inventoryTable{Name: "role_groups", Columns: []tableColumn{text("namespace"), text("mount_path"), text("kind"), text("name"), text("group_name")}}
+	secretsEngines := &inventoryTable{Name: "secrets_engines", Key: 2, Columns: []tableColumn{text("namespace"), text("mount_path"), text("type"), text("version"), integer("item_count")}}
+	engineRoles := &inventoryTable{Name: "engine_roles", Columns: []tableColumn{text("namespace"), text("mount_path"), text("name")}}
+	secrets := &inventoryTable{Name: "secrets", Key: 3, Columns: []tableColumn{text("namespace"), text("mount_path"), text("secret_path"), integer("current_version"), timestamp("creation_time"), timestamp("updated_time")}}
+	secretPolicies := &inventoryTable{Name: "secret_policies", Columns: []tableColumn{text("namespace"), text("secret_path"), text("policy"), text("access")}}
+	secretCapabilities := &inventoryTable{Name: "secret_capabilities", Columns: []tableColumn{text("namespace"), text("secret_path"), text("policy"), text("operation"), text("capabilities")}}
+	secretRoles := &inventoryTable{Name: "secret_roles", Columns: []tableColumn{text("namespace"), text("secret_path"), text("role")}}
+	entities := &inventoryTable{Name: "entities", Key: 2, Columns: []tableColumn{text("namespace"), text("entity_id"), text("name")}}
+	entityPolicies := &inventoryTable{Name: "entity_policies", Columns: []tableColumn{text("namespace"), text("entity_id"), text("policy"), text("source")}}
+	aliases := &inventoryTable{Name: "aliases", Columns: []tableColumn{text("namespace"), text("entity_id"), text("alias_id"), text("name"), text("mount_path"), text("mount_type")}}
+	groups := &inventoryTable{Name: "groups", Key: 2, Columns: []tableColumn{text("namespace"), text("group_id"), text("name"), text("type")}}
+	groupPolicies := &inventoryTable{Name: "group_policies", Columns: []tableColumn{text("namespace"), text("group_id"), text("policy")}}
+	groupMembers := &inventoryTable{Name: "group_members", Columns: []tableColumn{text("namespace"), text("group_id"), text("member_type"), text("member_id")}}
+	effectivePolicies := &inventoryTable{Name: "effective_policies", Columns: []tableColumn{text("namespace"), text("entity_id"), text("policy"), text("source")}}
+	effectivePaths := &inventoryTable{Name: "effective_paths", Columns: []tableColumn{text("namespace"), text("entity_id"), text("path"), text("policy"), text("capabilities")}}
+	effectiveSecrets := &inventoryTable{Name: "effective_secrets", Columns: []tableColumn{text("namespace"), text("entity_id"), text("secret_path"), text("operation"), text("capabilities")}}
+	unresolvedAliases := &inventoryTable{Name: "unresolved_aliases", Columns: []tableColumn{text("namespace"), text("entity_id"), text("alias")}}
+	errors := &inventoryTable{Name: "errors", Columns: []tableColumn{text("namespace"), text("error")}}
+
+	addUsage := func(namespace interface{}, u usageData) {
+		if u == (usageData{}) {
+			return
+		}
+		usage.add(namespace, numberValue(u.DistinctEntities), numberValue(u.Clients), numberValue(u.NonEntityClients), numberValue(u.SecretSyncs), numberValue(u.AcmeClients))
+	}
+
+	// addAccess adds one row per KV API path on which the access grants any
+	// capabilities, made up of the given key values followed by the path and
+	// its capabilities
+	addAccess := func(table *inventoryTable, access secretAccess, keys ...interface{}) {
+		for _, operation := range kvOperations {
+			if capabilities := access.capabilities(operation); len(capabilities) > 0 {
+				row := append(append([]interface{}{}, keys...), operation, strings.Join(capabilities, ","))
+				table.add(row...)
+			}
+		}
+	}
+
+	for _, ns := range i.Namespaces {
+		policiesOnly := int64(0)
+		if ns.PoliciesOnly {
+			policiesOnly = 1
+		}
+		namespaces.add(ns.Name, nullIfEmpty(ns.Parent), policiesOnly)
+		addUsage(ns.Name, ns.Usage)
+
+		for _, p := range ns.Policies {
+			policies.add(ns.Name, p.Name)
+			for _, rule := range p.Rules {
+				policyRules.add(ns.Name, p.Name, rule.Path, strings.Join(rule.Capabilities, ","), parametersValue(rule.AllowedParameters), parametersValue(rule.DeniedParameters), nullIfEmpty(strings.Join(rule.RequiredParameters, ",")), nullIfEmpty(rule.MinWrappingTTL), nullIfEmpty(rule.MaxWrappingTTL))
+			}
+		}
+
+		for _, am := range ns.AuthMounts {
+			authMounts.add(ns.Name, am.Path, am.Type)
+			for _, kind := range []struct {
+				name  string
+				items []authRole
+			}{{"role", am.Roles}, {"user", am.Users}, {"group", am.Groups}, {"cert", am.Certs}} {
+				for _, item := range kind.items {
+					authRoles.add(ns.Name, am.Path, kind.name, item.Name)
+					for _, policy := range item.Policies {
+						rolePolicies.add(ns.Name, am.Path, kind.name, item.Name, policy)
+					}
+					for _, group := range item.Groups {
+						roleGroups.add(ns.Name, am.Path, kind.name, item.Name, group)
+					}
+				}
+			}
+		}
+
+		for _, engine := range ns.SecretsEngines {
+			secretsEngines.add(ns.Name, engine.Path, engine.Type, nullIfEmpty(engine.Version), int64(engine.ItemCount))
+			for _, role := range engine.Roles {
+				engineRoles.add(ns.Name, engine.Path, role)
+			}
+
+			for _, secret := range engine.Secrets {
+				secrets.add(ns.Name, engine.Path, secret.Path, numberValue(secret.CurrentVersion), timeValue(secret.CreationTime), timeValue(secret.UpdatedTime))
+
+				for _, access := range secret.Access {
+					addAccess(secretCapabilities, access, ns.Name, secret.Path, access.Policy)
+				}
+				for _, kind := range []struct {
+					access   string
+					policies []string
+				}{
+					{"any", secret.Policies},
+					{"read", secret.ReadPolicies},
+					{"write", secret.WritePolicies},
+					{"delete", secret.DeletePolicies},
+					{"metadata", secret.MetadataPolicies},
+					{"undelete", secret.UndeletePolicies},
+					{"destroy", secret.DestroyPolicies},
+				} {
+					for _, policy := range kind.policies {
+						secretPolicies.add(ns.Name, secret.Path, policy, kind.access)
+					}
+				}
+				for _, role := range secret.Roles {
+					secretRoles.add(ns.Name, secret.Path, role)
+				}
+			}
+		}
+
+		for _, e := range ns.Entities {
+			entities.add(ns.Name, e.ID, e.Name)
+			for _, policy := range e.Policies {
+				entityPolicies.add(ns.Name, e.ID, policy, "entity")
+			}
+			for _, policy := range e.GroupPolicies {
+				entityPolicies.add(ns.Name, e.ID, policy, "group")
+			}
+			for _, a := range e.Aliases {
+				aliases.add(ns.Name, e.ID, a.ID, a.Name, a.MountPath, a.MountType)
+			}
+		}
+
+		for _, g := range ns.Groups {
+			groups.add(ns.Name, g.ID, g.Name, g.Type)
+			for _, policy := range g.Policies {
+				groupPolicies.add(ns.Name, g.ID, policy)
+			}
+			for _, id := range g.MemberEntityIDs {
+				groupMembers.add(ns.Name, g.ID, "entity", id)
+			}
+			for _, id := range g.MemberGroupIDs {
+				groupMembers.add(ns.Name, g.ID, "group", id)
+			}
+		}
+
+		for _, err := range ns.Errors {
+			errors.add(ns.Name, err)
+		}
+	}
+
+	for _, permissions := range i.EffectivePermissions {
+		for _, source := range permissions.Policies {
+			effectivePolicies.add(permissions.Namespace, permissions.EntityID, source.Policy, source.Source)
+		}
+		for _, grant := range permissions.Paths {
+			effectivePaths.add(permissions.Namespace, permissions.EntityID, grant.Path, grant.Policy, strings.Join(grant.Capabilities, ","))
+		}
+		for _, grant := range permissions.Secrets {
+			addAccess(effectiveSecrets, grant.secretAccess, permissions.Namespace, permissions.EntityID, grant.Path)
+		}
+		for _, a := range permissions.UnresolvedAliases {
+			unresolvedAliases.add(permissions.Namespace, permissions.EntityID, a)
+		}
+	}
+
+	// cluster-wide usage and errors are not tied to a namespace
+	addUsage(nil, i.Usage)
+	for _, err := range i.Errors {
+		errors.add(nil, err)
+	}
+
+	return []*inventoryTable{
+		namespaces, usage, policies, policyRules,
+		authMounts, authRoles, rolePolicies, roleGroups,
+		secretsEngines, engineRoles, secrets, secretPolicies, secretCapabilities, secretRoles,
+		entities, entityPolicies, aliases,
+		groups, groupPolicies, groupMembers,
+		effectivePolicies, effectivePaths, effectiveSecrets, unresolvedAliases,
+		errors,
+	}
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// parametersValue returns the allowed or denied parameters of a policy rule
+// as a JSON object, mapping each parameter to its values, or nil if there are
+// none.
+func parametersValue(parameters map[string][]interface{}) interface{} {
+	if len(parameters) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(parameters)
+	if err != nil {
+		return nil
+	}
+	return string(data)
+}
+
+// numberValue returns a number reported by Vault as an int64, or nil if it is
+// missing or not an integer.
+func numberValue(n json.Number) interface{} {
+	v, err := n.Int64()
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// timeValue returns a timestamp reported by Vault as a time.Time, or nil if it
+// is missing or invalid.
+func timeValue(s string) interface{} {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil
+	}
+	return t
+}
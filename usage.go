@@ -17,7 +17,7 @@ type usageData struct {
 }
 
 func (i *vaultInventory) getUsageData(c *clientConfig) {
-	path := "sys/internal/counters/activity/monthly"
+	path := utils.SetNamespacePath(c.BaseNamespace) + "sys/internal/counters/activity/monthly"
 	activity, err := c.Client.Read(c.Ctx, path)
 	if err != nil || activity.Data == nil {
 		utils.AppendError(fmt.Sprintf("error reading path %s: %v", path, err), &i.Errors)
@@ -27,7 +27,7 @@ func (i *vaultInventory) getUsageData(c *clientConfig) {
 	extractUsageData(activity.Data, i)
 
 	if byNamespace, ok := activity.Data["by_namespace"].([]interface{}); ok {
-		processNamespaceUsage(byNamespace, i)
+		processNamespaceUsage(byNamespace, i, c.BaseNamespace)
 	} else {
 		utils.AppendError(fmt.Sprintf("invalid type for 'by_namespace' in path %s", path), &i.Errors)
 	}
@@ -49,7 +49,7 @@ func extractUsageData(data map[string]interface{}, i *vaultInventory) {
 	extractNumber("acme_clients", &i.Usage.AcmeClients)
 }
 
-func processNamespaceUsage(byNamespace []interface{}, i *vaultInventory) {
+func processNamespaceUsage(byNamespace []interface{}, i *vaultInventory, baseNamespace string) {
 	for _, nsData := range byNamespace {
 		nsMap, ok := nsData.(map[string]interface{})
 		if !ok {
@@ -57,13 +57,24 @@ func processNamespaceUsage(byNamespace []interface{}, i *vaultInventory) {
 			continue
 		}
 
-		discoveredName := nsMap["namespace_path"].(string)
+		discoveredName := strings.TrimSuffix(nsMap["namespace_path"].(string), "/")
 		if discoveredName == "" {
 			discoveredName = "root"
 		}
 
+		// namespace paths may be reported relative to the base namespace
+		// when the scan does not start at the root
+		candidates := []string{discoveredName}
+		if baseNamespace != "root" {
+			if discoveredName == "root" {
+				candidates = append(candidates, baseNamespace)
+			} else {
+				candidates = append(candidates, baseNamespace+"/"+discoveredName)
+			}
+		}
+
 		for idx, namespace := range i.Namespaces {
-			if strings.HasPrefix(discoveredName, namespace.Name) {
+			if utils.StringInSlice(namespace.Name, candidates) {
 				updateNamespaceUsage(nsMap, &namespace)
 				i.Namespaces[idx] = namespace
 				break